@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errInconsistent = errors.New("database has inconsistencies")
+
+// check reports orphan cart items, orders of deleted products and negative
+// stock. With -fix it deletes the orphan cart items and resets negative stock
+// to zero; orders are only reported, their lines keep name and price.
+func (a *admin) check(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "Delete orphan cart items and reset negative stock to zero")
+	fs.Parse(args)
+
+	problems := 0
+
+	items, err := a.DB.GetOrphanCartItems()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		fmt.Printf("cart item %s: product %s or user %s no longer exists\n", item.ID.Hex(), item.ProductID.Hex(), item.UserID.Hex())
+	}
+	if *fix && len(items) > 0 {
+		ids := make([]primitive.ObjectID, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		n, err := a.DB.DeleteCartItems(ids)
+		if err != nil {
+			return err
+		}
+		a.infoLog.Printf("Deleted %d orphan cart items", n)
+	} else {
+		problems += len(items)
+	}
+
+	orders, err := a.DB.GetOrdersWithMissingProducts()
+	if err != nil {
+		return err
+	}
+	for _, o := range orders {
+		for _, id := range o.Missing {
+			fmt.Printf("order %s: product %s no longer exists\n", o.OrderID.Hex(), id.Hex())
+		}
+	}
+	problems += len(orders)
+
+	products, err := a.DB.GetNegativeStockProducts()
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		fmt.Printf("product %s (%s): stock %d\n", p.ID.Hex(), p.Name, p.Stock)
+	}
+	if *fix && len(products) > 0 {
+		n, err := a.DB.ResetNegativeStock()
+		if err != nil {
+			return err
+		}
+		a.infoLog.Printf("Reset stock of %d products to zero", n)
+	} else {
+		problems += len(products)
+	}
+
+	a.infoLog.Printf("Orphan cart items: %d, orders of deleted products: %d, negative stock: %d", len(items), len(orders), len(products))
+	if problems > 0 {
+		return errInconsistent
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// importBatch is the number of documents written per bulk request.
+const importBatch = 500
+
+// maxLineSize fits the largest document MongoDB accepts.
+const maxLineSize = 16 << 20
+
+var dataCollections = []string{"users", "categories", "products", "orders", "reviews"}
+
+func (a *admin) collection(name string) (*mongo.Collection, error) {
+	switch name {
+	case "users":
+		return a.DB.Users, nil
+	case "categories":
+		return a.DB.Categories, nil
+	case "products":
+		return a.DB.Products, nil
+	case "orders":
+		return a.DB.Orders, nil
+	case "reviews":
+		return a.DB.Reviews, nil
+	}
+	return nil, fmt.Errorf("unknown collection %q, expected one of %v", name, dataCollections)
+}
+
+// export writes every document of the collections to <dir>/<name>.jsonl in
+// canonical Extended JSON, so ObjectIDs, dates and number types survive an
+// import.
+func (a *admin) export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Directory for the .jsonl files")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kzadmin export [-dir dir] [collection ...]\nCollections: %v (default all)\n", dataCollections)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	names := fs.Args()
+	if len(names) == 0 {
+		names = dataCollections
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		coll, err := a.collection(name)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*dir, name+".jsonl")
+		n, err := exportCollection(coll, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		a.infoLog.Printf("Exported %d documents from %s to %s", n, name, path)
+	}
+	return nil
+}
+
+func exportCollection(coll *mongo.Collection, path string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	cur, err := coll.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.TODO())
+
+	n := 0
+	for cur.Next(context.TODO()) {
+		line, err := bson.MarshalExtJSON(cur.Current, true, false)
+		if err != nil {
+			return n, err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+		n++
+	}
+	if err := cur.Err(); err != nil {
+		return n, err
+	}
+	if err := w.Flush(); err != nil {
+		return n, err
+	}
+	return n, f.Close()
+}
+
+// importData loads <dir>/<name>.jsonl files. Documents are matched by _id:
+// existing ones are replaced, the rest inserted. All files are read and
+// checked before anything is written, so a bad file does not leave a
+// collection dropped or half loaded. Afterwards the migrations run again to
+// bring documents of older exports up to date.
+func (a *admin) importData(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Directory with the .jsonl files")
+	drop := fs.Bool("drop", false, "Delete all documents of a collection before loading it")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kzadmin import [-dir dir] [-drop] [collection ...]\nCollections: %v (default all with a file)\n", dataCollections)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	names := fs.Args()
+	explicit := len(names) > 0
+	if !explicit {
+		names = dataCollections
+	}
+
+	type load struct {
+		name string
+		coll *mongo.Collection
+		docs []bson.D
+	}
+	var loads []load
+	for _, name := range names {
+		coll, err := a.collection(name)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*dir, name+".jsonl")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && !explicit {
+			continue
+		}
+		docs, err := readDocuments(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		loads = append(loads, load{name, coll, docs})
+	}
+
+	for _, l := range loads {
+		if *drop {
+			if _, err := l.coll.DeleteMany(context.TODO(), bson.M{}); err != nil {
+				return fmt.Errorf("%s: %w", l.name, err)
+			}
+		}
+		if err := importDocuments(l.coll, l.docs); err != nil {
+			return fmt.Errorf("%s: %w", l.name, err)
+		}
+		a.infoLog.Printf("Imported %d documents into %s", len(l.docs), l.name)
+	}
+	if len(loads) == 0 {
+		return nil
+	}
+
+	// Exports of older versions have the data shape of their time, so the
+	// migrations are applied to the imported documents as well.
+	if _, err := a.DB.RerunMigrations(); err != nil {
+		return err
+	}
+	a.infoLog.Println("Applied migrations to the imported documents")
+	return nil
+}
+
+// readDocuments parses a JSON Lines file. Every document must have an _id.
+func readDocuments(path string) ([]bson.D, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	var docs []bson.D
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(sc.Bytes(), true, &doc); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if _, ok := docID(doc); !ok {
+			return nil, fmt.Errorf("line %d: document has no _id", line)
+		}
+		docs = append(docs, doc)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// importDocuments upserts the documents by _id in batches.
+func importDocuments(coll *mongo.Collection, docs []bson.D) error {
+	for start := 0; start < len(docs); start += importBatch {
+		end := min(start+importBatch, len(docs))
+		batch := make([]mongo.WriteModel, 0, end-start)
+		for _, doc := range docs[start:end] {
+			id, _ := docID(doc)
+			batch = append(batch, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": id}).SetReplacement(doc).SetUpsert(true))
+		}
+		if _, err := coll.BulkWrite(context.TODO(), batch, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func docID(doc bson.D) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,110 @@
+// Command kzadmin is the maintenance tool of the marketplace: it moves data
+// in and out as JSON Lines, seeds a demo dataset, creates admin users and
+// checks the database for inconsistencies.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/repository"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const usage = `Usage: kzadmin <command> [flags]
+
+Commands:
+  export        write collections as JSON Lines files
+  import        load JSON Lines files into collections
+  seed          fill an empty database with demo data
+  create-admin  create an admin user
+  check         look for orphan cart items, orders of deleted products and negative stock
+  migrate       apply pending migrations and create indexes; -rerun applies all again
+
+Run "kzadmin <command> -h" for the flags of a command.
+`
+
+type admin struct {
+	DB             *models.MongoDB
+	UserRepository *repository.UserRepository
+	infoLog        *log.Logger
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func(*admin, []string) error{
+		"export":       (*admin).export,
+		"import":       (*admin).importData,
+		"seed":         (*admin).seed,
+		"create-admin": (*admin).createAdmin,
+		"check":        (*admin).check,
+		"migrate":      (*admin).migrate,
+	}
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+
+	// The .env file is optional here: DB_URL may come from the environment.
+	godotenv.Load()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("DB_URL")))
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		errorLog.Fatal(err)
+	}
+
+	db := client.Database("kazakh_aliexpress")
+	a := &admin{
+		DB: models.NewMongoDB(db),
+		UserRepository: &repository.UserRepository{
+			Collection: db.Collection("users"),
+		},
+		infoLog: log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+	}
+
+	if err := run(a, os.Args[2:]); err != nil {
+		errorLog.Fatal(err)
+	}
+}
+
+func (a *admin) migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	rerun := fs.Bool("rerun", false, "Apply all migrations again, also those already recorded")
+	fs.Parse(args)
+
+	migrate := a.DB.Migrate
+	if *rerun {
+		migrate = a.DB.RerunMigrations
+	}
+	applied, err := migrate()
+	for _, name := range applied {
+		a.infoLog.Printf("Applied migration %s", name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		a.infoLog.Println("No pending migrations")
+	}
+	return a.DB.EnsureIndexes()
+}
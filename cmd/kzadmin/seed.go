@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (a *admin) createAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := fs.String("email", "", "Email of the new admin (required)")
+	password := fs.String("password", "", "Password; read from standard input when empty")
+	fs.Parse(args)
+
+	if *email == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	if *password == "" {
+		return errors.New("password must not be empty")
+	}
+
+	taken, err := a.UserRepository.EmailTaken(*email)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("a user with email %s already exists", *email)
+	}
+
+	user, err := a.UserRepository.Insert(*email, *password, "admin")
+	if err != nil {
+		return err
+	}
+	a.infoLog.Printf("Created admin %s (%s)", user.Email, user.ID.Hex())
+	return nil
+}
+
+type demoCategory struct {
+	name       string
+	parent     string
+	attributes string
+}
+
+var demoCategories = []demoCategory{
+	{name: "Электроника"},
+	{name: "Телефондар", parent: "Электроника", attributes: "color|Түсі|select||қара,ақ,көк\nmemory|Жады, ГБ|number"},
+	{name: "Ноутбуктар", parent: "Электроника", attributes: "screen|Экран, дюйм|number"},
+	{name: "Киім", attributes: "size|Өлшемі|select||S,M,L,XL"},
+	{name: "Үй және бақ"},
+}
+
+type demoProduct struct {
+	sku      string
+	name     string
+	price    int64
+	stock    int
+	city     string
+	category string
+	seller   int
+	attrs    map[string]string
+	desc     string
+}
+
+var demoProducts = []demoProduct{
+	{"PH-001", "Смартфон Galaxy A55", 189990, 25, "Алматы", "Телефондар", 0, map[string]string{"color": "қара", "memory": "128"}, "6,6 дюймдік экран, 50 МП камера."},
+	{"PH-002", "Смартфон Redmi Note 13", 119990, 40, "Астана", "Телефондар", 0, map[string]string{"color": "көк", "memory": "256"}, "Ұзақ жұмыс істейтін батарея."},
+	{"PH-003", "Смартфон iPhone 15", 459990, 5, "Алматы", "Телефондар", 1, map[string]string{"color": "ақ", "memory": "128"}, ""},
+	{"NB-001", "Ноутбук Lenovo IdeaPad 5", 349990, 8, "Алматы", "Ноутбуктар", 0, map[string]string{"screen": "15.6"}, "Жұмыс пен оқуға арналған."},
+	{"NB-002", "Ноутбук ASUS Vivobook 14", 279990, 12, "Шымкент", "Ноутбуктар", 1, map[string]string{"screen": "14"}, ""},
+	{"CL-001", "Шапан, ұлттық өрнекпен", 45000, 15, "Шымкент", "Киім", 1, map[string]string{"size": "L"}, "Қолдан тігілген мерекелік шапан."},
+	{"CL-002", "Жүн свитер", 18990, 30, "Астана", "Киім", 0, map[string]string{"size": "M"}, ""},
+	{"HM-001", "Кесе жиынтығы, 6 дана", 12500, 50, "Алматы", "Үй және бақ", 1, nil, "Фарфор кеселер."},
+	{"HM-002", "Самаурын, электр", 38990, 0, "Қарағанды", "Үй және бақ", 0, nil, "Қазір сатылымда жоқ."},
+	{"HM-003", "Бақ шлангісі, 20 м", 7990, 60, "Қарағанды", "Үй және бақ", 0, nil, ""},
+}
+
+// seed fills an empty database with demo users, categories, products and
+// delivered orders with reviews. All demo users share one password.
+func (a *admin) seed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	password := fs.String("password", "demo12345", "Password of the demo users")
+	fs.Parse(args)
+
+	n, err := a.DB.Products.CountDocuments(context.TODO(), bson.M{})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return errors.New("the database already has products; seed only fills an empty one")
+	}
+
+	var sellers, customers []primitive.ObjectID
+	sellerInfo := []models.SellerInfo{
+		{CompanyName: "ЖК Алатау Электроникс", BIN: "900101300123", Address: "Алматы, Абай даңғылы, 10", VATRegistered: true},
+		{CompanyName: "ЖШС Дала Маркет", BIN: "850505400456", Address: "Шымкент, Тәуке хан даңғылы, 5"},
+	}
+	for i, info := range sellerInfo {
+		u, err := a.UserRepository.Insert(fmt.Sprintf("seller%d@demo.kz", i+1), *password, "seller")
+		if err != nil {
+			return err
+		}
+		if err := a.DB.UpdateSellerInfo(u.ID, info); err != nil {
+			return err
+		}
+		sellers = append(sellers, u.ID)
+	}
+	for i := 1; i <= 3; i++ {
+		u, err := a.UserRepository.Insert(fmt.Sprintf("customer%d@demo.kz", i), *password, "customer")
+		if err != nil {
+			return err
+		}
+		customers = append(customers, u.ID)
+	}
+
+	categories := map[string]primitive.ObjectID{}
+	for _, dc := range demoCategories {
+		c := models.Category{Name: dc.name, ParentID: categories[dc.parent]}
+		if c.Attributes, err = models.ParseAttributeDefs(dc.attributes); err != nil {
+			return err
+		}
+		if err := a.DB.AddCategory(c); err != nil {
+			return err
+		}
+		created, err := a.DB.GetCategoryBySlug(models.Slugify(dc.name))
+		if err != nil {
+			return err
+		}
+		categories[dc.name] = created.ID
+	}
+
+	var products []models.Product
+	for _, dp := range demoProducts {
+		p := models.Product{
+			ID:          primitive.NewObjectID(),
+			SKU:         dp.sku,
+			Name:        dp.name,
+			Price:       money.Tenge(dp.price),
+			Stock:       dp.stock,
+			City:        dp.city,
+			CategoryID:  categories[dp.category],
+			SellerID:    sellers[dp.seller],
+			Description: dp.desc,
+			Attributes:  dp.attrs,
+		}
+		if _, err := a.DB.Products.InsertOne(context.TODO(), p); err != nil {
+			return err
+		}
+		if err := a.DB.RecordPriceChange(p.ID, p.Price, models.PriceChangeCreated); err != nil {
+			return err
+		}
+		products = append(products, p)
+	}
+
+	var inStock []models.Product
+	for _, p := range products {
+		if p.Stock >= 2 {
+			inStock = append(inStock, p)
+		}
+	}
+
+	// Every customer gets a delivered order of a few products and reviews
+	// them, so ratings, analytics and recommendations have data.
+	reviews := 0
+	for i, customerID := range customers {
+		order := models.Order{ID: primitive.NewObjectID(), UserID: customerID, PaymentMethod: "card"}
+		for j := 0; j < 3; j++ {
+			p := inStock[(i*3+j)%len(inStock)]
+			item := models.OrderItem{ProductID: p.ID, Name: p.Name, Quantity: 1 + j%2, UnitPrice: p.Price, SellerID: p.SellerID}
+			order.Items = append(order.Items, item)
+			order.Subtotal = order.Subtotal.Add(item.Total())
+		}
+		order.TotalPrice = order.Subtotal
+		if err := a.DB.CreateOrder(order); err != nil {
+			return err
+		}
+		if err := a.DB.UpdateOrderStatus(order.ID, "Delivered"); err != nil {
+			return err
+		}
+
+		for j, item := range order.Items {
+			review := models.Review{UserID: customerID, ProductID: item.ProductID, Rating: 5 - (i+j)%3, Comment: "Тауар сипаттамаға сай келеді, жеткізу жылдам."}
+			if err := a.DB.SaveReview(review); err != nil {
+				return err
+			}
+			reviews++
+		}
+	}
+
+	a.infoLog.Printf("Seeded %d sellers, %d customers, %d categories, %d products, %d orders and %d reviews",
+		len(sellers), len(customers), len(categories), len(products), len(customers), reviews)
+	a.infoLog.Printf("Demo users: seller1@demo.kz, seller2@demo.kz, customer1@demo.kz … customer3@demo.kz, password %q", *password)
+	return nil
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"kazakh_aliexpress/internal/models"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -18,3 +21,134 @@ func (app *application) orderWorker() {
 		app.DB.CreateOrder(order)
 	}
 }
+
+func (app *application) cartExpiryWorker(maxAge, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := app.DB.ExpireCarts(maxAge)
+		if err != nil {
+			app.errorLog.Println("Failed to expire carts:", err)
+			continue
+		}
+		if n > 0 {
+			app.infoLog.Printf("Expired %d abandoned cart items", n)
+		}
+	}
+}
+
+// wishlistWatcher periodically tells customers about price drops and
+// restocks of the products in their wishlists.
+func (app *application) wishlistWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := app.DB.NotifyWishlistChanges()
+		if err != nil {
+			app.errorLog.Println("Failed to check wishlists:", err)
+			continue
+		}
+		if n > 0 {
+			app.infoLog.Printf("Sent %d wishlist notifications", n)
+		}
+	}
+}
+
+// saleScheduler starts and ends the sales sellers have scheduled.
+func (app *application) saleScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		started, ended, err := app.DB.UpdateSales()
+		if err != nil {
+			app.errorLog.Println("Failed to update sales:", err)
+			continue
+		}
+		if started > 0 || ended > 0 {
+			app.infoLog.Printf("Sales started: %d, ended: %d", started, ended)
+		}
+	}
+}
+
+// eventFlusher writes the recorded product events to the database. When ctx
+// is cancelled it flushes what is left once more and returns, so a restart
+// does not lose the events of the last interval.
+func (app *application) eventFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if _, err := app.events.Flush(); err != nil {
+				app.errorLog.Println("Failed to flush product events:", err)
+			}
+			return
+		}
+		if _, err := app.events.Flush(); err != nil {
+			app.errorLog.Println("Failed to flush product events:", err)
+		}
+	}
+}
+
+// popularityUpdater recomputes the popularity scores used for sorting the
+// catalog and the trending block on the home page.
+func (app *application) popularityUpdater(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := app.DB.UpdatePopularity()
+		if err != nil {
+			app.errorLog.Println("Failed to update popularity:", err)
+			continue
+		}
+		if n > 0 {
+			app.infoLog.Printf("Updated popularity of %d products", n)
+		}
+	}
+}
+
+// recommendationBuilder refreshes the precomputed "customers also bought" and
+// similar product lists.
+func (app *application) recommendationBuilder(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := app.DB.RebuildRecommendations()
+		if err != nil {
+			app.errorLog.Println("Failed to rebuild recommendations:", err)
+			continue
+		}
+		app.infoLog.Printf("Rebuilt recommendations for %d products", n)
+	}
+}
+
+// feedBuilder regenerates the product feeds for price aggregators.
+func (app *application) feedBuilder(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := app.feeds.Rebuild()
+		if err != nil {
+			app.errorLog.Println("Failed to build product feeds:", err)
+			continue
+		}
+		app.infoLog.Printf("Built %d product feeds", n)
+	}
+}
+
+// runImport applies a started product import.
+func (app *application) runImport(imp *models.Import) {
+	if err := app.DB.RunImport(imp); err != nil {
+		app.errorLog.Printf("Import %s failed: %v", imp.ID.Hex(), err)
+		return
+	}
+	app.infoLog.Printf("Import %s finished", imp.ID.Hex())
+}
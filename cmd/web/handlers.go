@@ -3,16 +3,25 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"kazakh_aliexpress/internal/analytics"
+	"kazakh_aliexpress/internal/documents"
+	"kazakh_aliexpress/internal/events"
+	"kazakh_aliexpress/internal/feeds"
 	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/money"
+	"kazakh_aliexpress/internal/spreadsheet"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func (app *application) addDefaultData(td *TemplateData, r *http.Request) *TemplateData {
@@ -20,11 +29,26 @@ func (app *application) addDefaultData(td *TemplateData, r *http.Request) *Templ
 		td = &TemplateData{}
 	}
 	td.CurrentYear = time.Now().Year()
+	td.Currencies = app.rates.Currencies()
+	td.Currency = app.session.GetString(r.Context(), "currency")
+	if td.Currency == "" {
+		td.Currency = money.KZT
+	}
+	td.Documents = app.pdfFont != nil
 	td.IsAuthenticated = app.isAuthenticated(r)
+	if td.Flash == "" {
+		td.Flash = app.session.PopString(r.Context(), "flash")
+	}
 
 	if td.IsAuthenticated {
+		td.UserID = app.session.GetString(r.Context(), "authenticatedUserID")
 		td.UserRole = app.session.GetString(r.Context(), "userRole")
 		td.UserName = app.session.GetString(r.Context(), "userEmail")
+
+		if td.UserRole == "customer" {
+			uid, _ := primitive.ObjectIDFromHex(td.UserID)
+			td.UnreadNotifications, _ = app.DB.CountUnreadNotifications(uid)
+		}
 	}
 	return td
 }
@@ -60,13 +84,23 @@ func (app *application) register(w http.ResponseWriter, r *http.Request) {
 		role = "customer"
 	}
 
-	err := app.UserRepository.Insert(email, password, role)
+	user, err := app.UserRepository.Insert(email, password, role)
+	if mongo.IsDuplicateKeyError(err) {
+		app.session.Put(r.Context(), "flash", "Бұл email бойынша тіркелгі бар")
+		http.Redirect(w, r, "/register", http.StatusSeeOther)
+		return
+	}
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	if err := app.logIn(r, user); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
@@ -84,11 +118,34 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := app.logIn(r, user); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// logIn starts an authenticated session and folds any cart the visitor
+// filled as a guest into the user's cart.
+func (app *application) logIn(r *http.Request, user models.User) error {
+	if err := app.session.RenewToken(r.Context()); err != nil {
+		return err
+	}
+
 	app.session.Put(r.Context(), "authenticatedUserID", user.ID.Hex())
 	app.session.Put(r.Context(), "userRole", user.Role)
 	app.session.Put(r.Context(), "userEmail", user.Email)
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	if ids := parseObjectIDs(app.session.PopString(r.Context(), "recentlyViewed")); len(ids) > 0 {
+		if err := app.DB.AddRecentlyViewed(user.ID, ids...); err != nil {
+			return err
+		}
+	}
+	if guestID := app.session.PopString(r.Context(), "guestCartID"); guestID != "" {
+		return app.DB.MergeGuestCart(guestID, user.ID)
+	}
+	return nil
 }
 
 func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
@@ -103,7 +160,59 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
-	app.render(w, r, "home.page.tmpl", &TemplateData{Products: products})
+
+	city := r.URL.Query().Get("city")
+	if r.URL.Query().Has("city") {
+		app.session.Put(r.Context(), "city", city)
+	} else {
+		city = app.session.GetString(r.Context(), "city")
+	}
+	trending, err := app.DB.GetTrendingProducts(city, 8)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	cities, _ := app.DB.GetUniqueCities()
+
+	app.render(w, r, "home.page.tmpl", &TemplateData{
+		Products:   products,
+		Wishlisted: app.wishlisted(r),
+		Trending:   trending,
+		City:       city,
+		Cities:     cities,
+
+		RecentlyViewed: app.recentlyViewed(r, primitive.NilObjectID, 8),
+	})
+}
+
+func (app *application) showProfile(w http.ResponseWriter, r *http.Request) {
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	user, err := app.DB.GetUser(uid)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "profile.page.tmpl", &TemplateData{
+		User:           user,
+		RecentlyViewed: app.recentlyViewed(r, primitive.NilObjectID, models.RecentlyViewedLimit),
+	})
+}
+
+func (app *application) clearRecentlyViewed(w http.ResponseWriter, r *http.Request) {
+	uid, err := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.session.Remove(r.Context(), "recentlyViewed")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if err := app.DB.ClearRecentlyViewed(uid); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Put(r.Context(), "flash", "Қаралған тауарлар тарихы тазаланды")
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
 }
 
 func (app *application) listOrdersPage(w http.ResponseWriter, r *http.Request) {
@@ -122,265 +231,1183 @@ func (app *application) listOrdersPage(w http.ResponseWriter, r *http.Request) {
 func (app *application) showOrder(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	oid, _ := primitive.ObjectIDFromHex(id)
-	order, _ := app.DB.GetOrder(oid)
-	app.render(w, r, "order_details.page.tmpl", &TemplateData{Order: order})
-}
-
-func (app *application) completePayment(w http.ResponseWriter, r *http.Request) {
-	oid, _ := primitive.ObjectIDFromHex(r.FormValue("order_id"))
-	app.DB.UpdateOrderStatus(oid, "Paid")
-	http.Redirect(w, r, "/orders", http.StatusSeeOther)
-}
-
-func (app *application) addReview(w http.ResponseWriter, r *http.Request) {
-	pid, _ := primitive.ObjectIDFromHex(r.FormValue("product_id"))
-	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
-
-	rating, _ := strconv.Atoi(r.FormValue("rating"))
+	order, err := app.DB.GetOrder(oid)
+	if err != nil || !app.canAccessOrder(r, order) {
+		app.notFound(w)
+		return
+	}
 
-	app.DB.AddReview(models.Review{
-		ProductID: pid,
-		UserID:    uid,
-		Rating:    rating,
-		Comment:   r.FormValue("comment")})
+	returns, _ := app.DB.GetReturnsByOrder(order.ID)
+	payment, err := app.DB.GetPaymentByOrder(order.ID)
+	if err != nil {
+		payment = nil
+	}
 
-	http.Redirect(w, r, "/product?id="+pid.Hex(), http.StatusSeeOther)
+	app.render(w, r, "order_details.page.tmpl", &TemplateData{Order: order, Returns: returns, Payment: payment})
 }
 
-func (app *application) sellerDashboard(w http.ResponseWriter, r *http.Request) {
-	sellerIDHex := app.session.GetString(r.Context(), "authenticatedUserID")
-	sellerID, _ := primitive.ObjectIDFromHex(sellerIDHex)
-
-	var products []*models.Product
-	cursor, err := app.DB.Products.Find(r.Context(), bson.M{"seller_id": sellerID})
-	if err == nil {
-		cursor.All(r.Context(), &products)
+func (app *application) showReceipt(w http.ResponseWriter, r *http.Request) {
+	oid, _ := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	order, err := app.DB.GetOrder(oid)
+	if err != nil || !app.canAccessOrder(r, order) {
+		app.notFound(w)
+		return
 	}
 
-	categories, err := app.DB.GetAllCategories()
+	receipt, err := app.DB.GetReceipt(order.ID)
+	if errors.Is(err, models.ErrNotPaid) {
+		app.session.Put(r.Context(), "flash", "Чек тапсырыс төленгеннен кейін қолжетімді болады")
+		http.Redirect(w, r, "/order?id="+order.ID.Hex(), http.StatusSeeOther)
+		return
+	}
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	data := &TemplateData{
-		Products:   products,
-		Categories: categories,
-	}
-
-	app.render(w, r, "seller_dashboard.page.tmpl", data)
+	app.render(w, r, "receipt.page.tmpl", &TemplateData{Receipt: receipt})
 }
 
-func (app *application) createProduct(w http.ResponseWriter, r *http.Request) {
-	sellerIDHex := app.session.GetString(r.Context(), "authenticatedUserID")
-	sellerID, _ := primitive.ObjectIDFromHex(sellerIDHex)
-	price, _ := strconv.ParseFloat(r.FormValue("price"), 64)
-	catIDHex := r.PostFormValue("category_id")
+func (app *application) downloadInvoice(w http.ResponseWriter, r *http.Request) {
+	oid, _ := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	order, err := app.DB.GetOrder(oid)
+	if err != nil || !app.canAccessOrder(r, order) {
+		app.notFound(w)
+		return
+	}
+	if app.pdfFont == nil {
+		app.clientError(w, http.StatusServiceUnavailable)
+		return
+	}
 
-	catID, err := primitive.ObjectIDFromHex(catIDHex)
+	inv, err := app.DB.GetInvoice(order.ID)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-
-	newP := models.Product{
-		ID:          primitive.NewObjectID(),
-		Name:        r.FormValue("name"),
-		Price:       price,
-		Stock:       10,
-		City:        r.FormValue("city"),
-		CategoryID:  catID,
-		SellerID:    sellerID,
-		Description: r.FormValue("description"),
+	data, err := documents.Invoice(app.pdfFont, inv)
+	if err != nil {
+		app.serverError(w, err)
+		return
 	}
-	app.DB.Products.InsertOne(r.Context(), newP)
-	http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
-}
-
-func (app *application) deleteProduct(w http.ResponseWriter, r *http.Request) {
-	app.DB.DeleteProduct(r.FormValue("id"))
-	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	app.servePDF(w, "invoice-"+order.ID.Hex()+".pdf", data)
 }
 
-func (app *application) addCategory(w http.ResponseWriter, r *http.Request) {
-	app.DB.AddCategory(r.FormValue("name"))
-	http.Redirect(w, r, "/admin", http.StatusSeeOther)
-}
+// downloadPackingSlip gives a seller the packing slip for their lines of an
+// order. Admins get the slips of all sellers in the order.
+func (app *application) downloadPackingSlip(w http.ResponseWriter, r *http.Request) {
+	oid, _ := primitive.ObjectIDFromHex(r.URL.Query().Get("order_id"))
+	if app.pdfFont == nil {
+		app.clientError(w, http.StatusServiceUnavailable)
+		return
+	}
 
-func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
-	products, err := app.DB.GetAllProducts()
+	inv, err := app.DB.GetInvoice(oid)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		app.notFound(w)
+		return
+	}
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	revenue, err := app.DB.GetTotalRevenue()
-	if err != nil {
-		revenue = 0
+	groups := inv.Sellers
+	if app.session.GetString(r.Context(), "userRole") != "admin" {
+		sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+		g := inv.Lines(sellerID)
+		if g == nil {
+			app.notFound(w)
+			return
+		}
+		groups = []*models.SellerLines{g}
 	}
 
-	totalOrders, err := app.DB.GetTotalOrderCount()
+	data, err := documents.PackingSlip(app.pdfFont, inv, groups)
 	if err != nil {
-		totalOrders = 0
+		app.serverError(w, err)
+		return
 	}
-
-	data := app.addDefaultData(&TemplateData{
-		Products:     products,
-		TotalRevenue: revenue,
-		TotalOrders:  int(totalOrders),
-	}, r)
-
-	app.render(w, r, "admin_dashboard.page.tmpl", data)
-}
-
-func (app *application) listUsers(w http.ResponseWriter, r *http.Request) {
-	users, _ := app.DB.GetAllUsers()
-	app.render(w, r, "admin_users.page.tmpl", &TemplateData{Users: users})
+	app.servePDF(w, "packing-slip-"+oid.Hex()+".pdf", data)
 }
 
-func (app *application) deleteUser(w http.ResponseWriter, r *http.Request) {
-	oid, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
-	app.DB.DeleteUser(oid)
-	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
-}
+func (app *application) downloadStatement(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	month, err := time.ParseInLocation("2006-01", r.URL.Query().Get("month"), time.Local)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if app.pdfFont == nil {
+		app.clientError(w, http.StatusServiceUnavailable)
+		return
+	}
 
-func (app *application) adminOrders(w http.ResponseWriter, r *http.Request) {
-	orders, _ := app.DB.GetAllOrders()
-	app.render(w, r, "admin_orders.page.tmpl", &TemplateData{Orders: orders})
+	st, err := app.DB.GetSellerStatement(sellerID, month)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data, err := documents.Statement(app.pdfFont, st)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.servePDF(w, "statement-"+month.Format("2006-01")+".pdf", data)
 }
 
-func (app *application) updateOrderStatus(w http.ResponseWriter, r *http.Request) {
-	oid, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
-	app.DB.UpdateOrderStatus(oid, r.FormValue("status"))
-	http.Redirect(w, r, "/admin/orders", http.StatusSeeOther)
-}
+func (app *application) completePayment(w http.ResponseWriter, r *http.Request) {
+	oid, _ := primitive.ObjectIDFromHex(r.FormValue("order_id"))
+	order, err := app.DB.GetOrder(oid)
+	if err != nil || !app.canAccessOrder(r, order) {
+		app.notFound(w)
+		return
+	}
 
-func (app *application) apiProducts(w http.ResponseWriter, r *http.Request) {
-	p, _ := app.DB.GetAllProducts()
-	json.NewEncoder(w).Encode(p)
-}
+	if order.Status == "Pending" {
+		_, err = app.DB.CreatePayment(models.Payment{
+			OrderID: order.ID,
+			Amount:  order.TotalPrice,
+			Status:  "Paid",
+			Method:  order.PaymentMethod,
+		})
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.DB.UpdateOrderStatus(oid, "Paid")
+		for _, item := range order.Items {
+			app.events.Record(app.visitorID(r), events.Purchase, item.ProductID)
+		}
+	}
 
-func (app *application) apiListOrders(w http.ResponseWriter, r *http.Request) {
-	o, _ := app.DB.GetAllOrders()
-	json.NewEncoder(w).Encode(o)
+	http.Redirect(w, r, "/orders", http.StatusSeeOther)
 }
 
-func (app *application) showCart(w http.ResponseWriter, r *http.Request) {
-	userIDStr := app.session.GetString(r.Context(), "authenticatedUserID")
-	userID, _ := primitive.ObjectIDFromHex(userIDStr)
-
-	cartItems, err := app.DB.GetUserCart(userID)
-	if err != nil {
-		app.serverError(w, err)
+func (app *application) cancelOrder(w http.ResponseWriter, r *http.Request) {
+	oid, _ := primitive.ObjectIDFromHex(r.FormValue("order_id"))
+	order, err := app.DB.GetOrder(oid)
+	if err != nil || !app.canAccessOrder(r, order) {
+		app.notFound(w)
 		return
 	}
 
-	var grandTotal float64
-	for _, item := range cartItems {
-		item.Total = item.Price * float64(item.Quantity)
-		grandTotal += item.Total
+	err = app.DB.CancelOrder(order.ID)
+	if errors.Is(err, models.ErrOrderNotCancellable) {
+		app.clientError(w, http.StatusConflict)
+		return
 	}
-
-	data := app.addDefaultData(&TemplateData{}, r)
-	data.Cart = &models.Cart{
-		Items:      cartItems,
-		TotalPrice: grandTotal,
+	if err != nil {
+		app.serverError(w, err)
+		return
 	}
 
-	app.render(w, r, "cart.page.tmpl", data)
+	app.session.Put(r.Context(), "flash", "Тапсырыстан бас тартылды")
+	http.Redirect(w, r, "/order?id="+order.ID.Hex(), http.StatusSeeOther)
 }
 
-func (app *application) removeFromCart(w http.ResponseWriter, r *http.Request) {
-	pid, err := primitive.ObjectIDFromHex(r.FormValue("product_id"))
-	if err != nil {
+func (app *application) requestReturn(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
+	oid, _ := primitive.ObjectIDFromHex(r.FormValue("order_id"))
+	pid, _ := primitive.ObjectIDFromHex(r.FormValue("product_id"))
 	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	qty, _ := strconv.Atoi(r.FormValue("quantity"))
 
-	filter := bson.M{"user_id": uid, "product_id": pid}
-	_, err = app.DB.Users.Database().Collection("cart").DeleteOne(r.Context(), filter)
-	if err != nil {
-		app.serverError(w, err)
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	http.Redirect(w, r, "/cart", http.StatusSeeOther)
-}
-
-func (app *application) updateProductForm(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	product, err := app.DB.GetProduct(id)
+	photos, err := app.saveUploads(r.MultipartForm.File["photos"], "returns")
 	if err != nil {
-		app.notFound(w)
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	categories, _ := app.DB.GetAllCategories()
-
-	app.render(w, r, "update_product.page.tmpl", &TemplateData{
-		Product:    product,
-		Categories: categories,
+	err = app.DB.CreateReturnRequest(models.ReturnRequest{
+		OrderID:   oid,
+		UserID:    uid,
+		ProductID: pid,
+		Quantity:  qty,
+		Reason:    reason,
+		Photos:    photos,
 	})
-}
-
-func (app *application) updateProduct(w http.ResponseWriter, r *http.Request) {
-	idHex := r.FormValue("id")
-	oid, _ := primitive.ObjectIDFromHex(idHex)
-	price, _ := strconv.ParseFloat(r.FormValue("price"), 64)
-	catID, _ := primitive.ObjectIDFromHex(r.FormValue("category_id"))
-
-	updatedP := models.Product{
-		ID:          oid,
-		Name:        r.FormValue("name"),
-		Price:       price,
-		City:        r.FormValue("city"),
-		CategoryID:  catID,
-		Description: r.FormValue("description"),
+	if errors.Is(err, models.ErrReturnNotAllowed) {
+		app.clientError(w, http.StatusUnprocessableEntity)
+		return
 	}
-
-	err := app.DB.UpdateProduct(updatedP)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.session.Put(r.Context(), "flash", "Product updated successfully!")
-	http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
+	app.session.Put(r.Context(), "flash", "Қайтару өтініші жіберілді")
+	http.Redirect(w, r, "/order?id="+oid.Hex(), http.StatusSeeOther)
 }
 
-func (app *application) addToCart(w http.ResponseWriter, r *http.Request) {
-	pidHex := r.FormValue("product_id")
-	pid, _ := primitive.ObjectIDFromHex(pidHex)
-	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+func (app *application) listReturns(w http.ResponseWriter, r *http.Request) {
+	var returns []*models.ReturnRequest
+	var err error
 
-	product, err := app.DB.GetProduct(pidHex)
+	if app.session.GetString(r.Context(), "userRole") == "admin" {
+		returns, err = app.DB.GetAllReturns()
+	} else {
+		sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+		returns, err = app.DB.GetReturnsBySeller(sellerID)
+	}
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.render(w, r, "returns.page.tmpl", &TemplateData{Returns: returns})
+}
+
+func (app *application) resolveReturn(w http.ResponseWriter, r *http.Request) {
+	id, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+	rr, err := app.DB.GetReturnRequest(id)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	if app.session.GetString(r.Context(), "userRole") != "admin" &&
+		rr.SellerID.Hex() != app.session.GetString(r.Context(), "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.DB.ResolveReturn(rr.ID, r.FormValue("action") == "approve")
+	if errors.Is(err, models.ErrReturnResolved) {
+		app.clientError(w, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/returns", http.StatusSeeOther)
+}
+
+func (app *application) addReview(w http.ResponseWriter, r *http.Request) {
+	pid, _ := primitive.ObjectIDFromHex(r.FormValue("product_id"))
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	rating, _ := strconv.Atoi(r.FormValue("rating"))
+
+	comment := strings.TrimSpace(r.FormValue("comment"))
+
+	err := app.DB.SaveReview(models.Review{
+		ProductID: pid,
+		UserID:    uid,
+		Rating:    rating,
+		Comment:   comment,
+		Flags:     app.reviewFilter.Check(comment)})
+	if errors.Is(err, models.ErrInvalidRating) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrReviewNotAllowed) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/product?id="+pid.Hex(), http.StatusSeeOther)
+}
+
+func (app *application) deleteReview(w http.ResponseWriter, r *http.Request) {
+	id, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+	review, err := app.DB.GetReview(id)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	if app.session.GetString(r.Context(), "userRole") != "admin" &&
+		review.UserID.Hex() != app.session.GetString(r.Context(), "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	if err := app.DB.DeleteReview(review.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/product?id="+review.ProductID.Hex(), http.StatusSeeOther)
+}
+
+func (app *application) replyToReview(w http.ResponseWriter, r *http.Request) {
+	id, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+	review, err := app.DB.GetReview(id)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	product, err := app.DB.GetProductByOID(review.ProductID)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	if product.SellerID.Hex() != app.session.GetString(r.Context(), "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.DB.SetReviewReply(review.ID, strings.TrimSpace(r.FormValue("reply")))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/product?id="+product.ID.Hex(), http.StatusSeeOther)
+}
+
+func (app *application) reportReview(w http.ResponseWriter, r *http.Request) {
+	id, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	review, err := app.DB.GetReview(id)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	err = app.DB.ReportReview(review.ID, uid, r.FormValue("reason"))
+	switch {
+	case errors.Is(err, models.ErrInvalidReportReason):
+		app.clientError(w, http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrOwnReview):
+		app.session.Put(r.Context(), "flash", "Өз пікіріңізге шағымдана алмайсыз")
+		http.Redirect(w, r, "/product?id="+review.ProductID.Hex(), http.StatusSeeOther)
+		return
+	case err != nil && !errors.Is(err, models.ErrAlreadyReported):
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Шағымыңыз модераторға жіберілді")
+	http.Redirect(w, r, "/product?id="+review.ProductID.Hex(), http.StatusSeeOther)
+}
+
+func (app *application) adminReviews(w http.ResponseWriter, r *http.Request) {
+	reviews, err := app.DB.GetModerationQueue()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "admin_reviews.page.tmpl", &TemplateData{Reviews: reviews})
+}
+
+func (app *application) moderateReview(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	switch r.FormValue("action") {
+	case "approve":
+		err = app.DB.SetReviewStatus(id, models.ReviewPublished)
+	case "hide":
+		err = app.DB.SetReviewStatus(id, models.ReviewHidden)
+	case "delete":
+		err = app.DB.DeleteReview(id)
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/reviews", http.StatusSeeOther)
+}
+
+func (app *application) sellerDashboard(w http.ResponseWriter, r *http.Request) {
+	sellerIDHex := app.session.GetString(r.Context(), "authenticatedUserID")
+	sellerID, _ := primitive.ObjectIDFromHex(sellerIDHex)
+
+	var products []*models.Product
+	cursor, err := app.DB.Products.Find(r.Context(), bson.M{"seller_id": sellerID})
+	if err == nil {
+		cursor.All(r.Context(), &products)
+	}
+
+	categories, err := app.DB.GetAllCategories()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	productIDs := make([]primitive.ObjectID, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.ID)
+	}
+	awaitingReply, err := app.DB.GetReviewsAwaitingReply(productIDs)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	seller, err := app.DB.GetUser(sellerID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	orders, err := app.DB.GetSellerOrders(sellerID, 20)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	report, err := analytics.Seller(app.DB, sellerID, analyticsRange(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	imports, err := app.DB.GetSellerImports(sellerID, 3)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := &TemplateData{
+		Products:   products,
+		Categories: categories,
+		Reviews:    awaitingReply,
+		User:       seller,
+		Orders:     orders,
+		Month:      time.Now().Format("2006-01"),
+
+		SellerAnalytics: report,
+		Imports:         imports,
+	}
+
+	app.render(w, r, "seller_dashboard.page.tmpl", data)
+}
+
+// sellerAnalyticsCSV exports the seller report for the selected period,
+// either per product or per day.
+func (app *application) sellerAnalyticsCSV(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	rng := analyticsRange(r)
+	report, err := analytics.Seller(app.DB, sellerID, rng)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var rows [][]string
+	kind := r.URL.Query().Get("kind")
+	if kind == "daily" {
+		rows = append(rows, []string{"period_start", "revenue", "units", "orders"})
+		for _, p := range report.Series {
+			rows = append(rows, []string{p.Start.Format("2006-01-02"), p.Revenue.Decimal(), strconv.FormatInt(p.Count, 10), strconv.FormatInt(p.Orders, 10)})
+		}
+	} else {
+		kind = "products"
+		rows = append(rows, []string{"product_id", "name", "units", "revenue", "orders", "views", "conversion_percent", "returned", "return_rate_percent", "rating_avg", "rating_count"})
+		for _, p := range report.Products {
+			rows = append(rows, []string{
+				p.ID.Hex(), p.Name,
+				strconv.FormatInt(p.Units, 10), p.Revenue.Decimal(), strconv.FormatInt(p.Orders, 10),
+				strconv.FormatInt(p.Views, 10), strconv.FormatFloat(p.Conversion(), 'f', 2, 64),
+				strconv.FormatInt(p.Returned, 10), strconv.FormatFloat(p.ReturnRate(), 'f', 2, 64),
+				strconv.FormatFloat(p.RatingAvg, 'f', 2, 64), strconv.Itoa(p.RatingCount),
+			})
+		}
+	}
+
+	filename := fmt.Sprintf("sales-%s-%s_%s.csv", kind, rng.FromDate(), rng.ToDate())
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := spreadsheet.WriteCSV(w, rows); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// sellerImport shows the upload form and recent imports, or the steps of one
+// import: column mapping, dry run report and progress.
+func (app *application) sellerImport(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	data := &TemplateData{ImportFields: models.ImportFields}
+	if idHex := r.URL.Query().Get("id"); idHex != "" {
+		id, _ := primitive.ObjectIDFromHex(idHex)
+		imp, err := app.DB.GetImport(id, sellerID)
+		if err != nil {
+			app.notFound(w)
+			return
+		}
+		data.Import = imp
+	} else {
+		imports, err := app.DB.GetSellerImports(sellerID, 20)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Imports = imports
+	}
+
+	app.render(w, r, "seller_import.page.tmpl", data)
+}
+
+func (app *application) uploadImport(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	fail := func(msg string) {
+		app.session.Put(r.Context(), "flash", msg)
+		http.Redirect(w, r, "/seller/import", http.StatusSeeOther)
+	}
+
+	format, err := spreadsheet.Format(header.Filename)
+	if err != nil {
+		fail("Тек CSV және XLSX файлдарын жүктеуге болады")
+		return
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	rows, err := spreadsheet.Read(format, content)
+	if err != nil {
+		fail("Файлды оқу мүмкін болмады: " + err.Error())
+		return
+	}
+	if len(rows) < 2 {
+		fail("Файлда тақырып жолы және кемінде бір тауар болуы керек")
+		return
+	}
+	if len(rows)-1 > models.MaxImportRows {
+		fail(fmt.Sprintf("Бір файлда %d тауардан көп болмауы керек", models.MaxImportRows))
+		return
+	}
+
+	imp := &models.Import{
+		SellerID: sellerID,
+		Filename: header.Filename,
+		Headers:  rows[0],
+		Rows:     rows[1:],
+		Mapping:  models.GuessImportMapping(rows[0]),
+	}
+	err = app.DB.CreateImport(imp)
+	if errors.Is(err, models.ErrImportTooLarge) {
+		fail("Файл тым үлкен: оны бірнеше бөлікке бөліп жүктеңіз")
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/seller/import?id="+imp.ID.Hex(), http.StatusSeeOther)
+}
+
+// previewImport saves the column mapping and runs the import dry.
+func (app *application) previewImport(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	id, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+	imp, err := app.DB.GetImport(id, sellerID)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	redirect := "/seller/import?id=" + imp.ID.Hex()
+
+	mapping := map[string]int{}
+	for _, f := range models.ImportFields {
+		col, err := strconv.Atoi(r.FormValue("map_" + f.Key))
+		if err == nil && col >= 0 && col < len(imp.Headers) {
+			mapping[f.Key] = col
+		}
+	}
+	if _, ok := mapping["sku"]; !ok {
+		app.session.Put(r.Context(), "flash", "Артикул бағанын таңдаңыз: тауарлар артикул бойынша жаңартылады")
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	err = app.DB.PreviewImport(imp, mapping)
+	if errors.Is(err, models.ErrImportNotReady) {
+		app.session.Put(r.Context(), "flash", "Импорт басталып кеткен, оны өзгертуге болмайды")
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func (app *application) startImport(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	id, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+
+	imp, err := app.DB.StartImport(id, sellerID)
+	if errors.Is(err, models.ErrImportNotReady) {
+		app.session.Put(r.Context(), "flash", "Алдымен алдын ала тексеруді іске қосыңыз")
+		http.Redirect(w, r, "/seller/import?id="+id.Hex(), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	go app.runImport(imp)
+
+	app.session.Put(r.Context(), "flash", "Импорт басталды")
+	http.Redirect(w, r, "/seller/import?id="+imp.ID.Hex(), http.StatusSeeOther)
+}
+
+func (app *application) exportProducts(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	format := r.URL.Query().Get("format")
+	if format != spreadsheet.XLSX {
+		format = spreadsheet.CSV
+	}
+	rows, err := app.DB.ExportProducts(sellerID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	filename := "products-" + time.Now().Format("2006-01-02") + "." + format
+	w.Header().Set("Content-Type", spreadsheet.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := spreadsheet.Write(format, w, rows); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// productFeed serves the cached product feeds: /feeds/yml.xml and
+// /feeds/google.xml for the marketplace, /feeds/seller/<id>/yml.xml and
+// /feeds/seller/<id>/google.xml for one seller.
+func (app *application) productFeed(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/feeds/")
+	var sellerID primitive.ObjectID
+	if sellerPath, ok := strings.CutPrefix(rest, "seller/"); ok {
+		idHex, name, found := strings.Cut(sellerPath, "/")
+		id, err := primitive.ObjectIDFromHex(idHex)
+		if !found || err != nil {
+			app.notFound(w)
+			return
+		}
+		sellerID, rest = id, name
+	}
+	format, ok := strings.CutSuffix(rest, ".xml")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	feed, ok := app.feeds.Get(format, sellerID)
+	if !ok {
+		// Unknown formats and sellers without products are missing for good,
+		// but right after a start nothing has been built yet.
+		if _, built := app.feeds.Get(feeds.YML, primitive.NilObjectID); !built {
+			w.Header().Set("Retry-After", "60")
+			app.clientError(w, http.StatusServiceUnavailable)
+			return
+		}
+		app.notFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	http.ServeContent(w, r, rest, feed.Generated, bytes.NewReader(feed.Data))
+}
+
+func (app *application) updateSellerProfile(w http.ResponseWriter, r *http.Request) {
+	sellerID, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	info := models.SellerInfo{
+		CompanyName:   strings.TrimSpace(r.FormValue("company_name")),
+		BIN:           strings.TrimSpace(r.FormValue("bin")),
+		Address:       strings.TrimSpace(r.FormValue("address")),
+		VATRegistered: r.FormValue("vat_registered") == "on",
+	}
+	err := app.DB.UpdateSellerInfo(sellerID, info)
+	if errors.Is(err, models.ErrInvalidSellerInfo) {
+		app.session.Put(r.Context(), "flash", "БСН 12 цифрдан тұруы керек, ҚҚС төлеушілер компания атауы мен БСН-ді толтыруы қажет")
+		http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Сатушы деректері сақталды")
+	http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
+}
+
+func (app *application) createProduct(w http.ResponseWriter, r *http.Request) {
+	sellerIDHex := app.session.GetString(r.Context(), "authenticatedUserID")
+	sellerID, _ := primitive.ObjectIDFromHex(sellerIDHex)
+	price, _ := money.Parse(r.FormValue("price"))
+	catIDHex := r.PostFormValue("category_id")
+
+	catID, err := primitive.ObjectIDFromHex(catIDHex)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	attrs, err := app.productAttributes(r, catID)
+	var attrErr *models.AttributeError
+	if errors.As(err, &attrErr) {
+		app.session.Put(r.Context(), "flash", "Сипаттама толтырылмаған немесе қате: "+attrErr.Label)
+		http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		app.session.Put(r.Context(), "flash", "Санат табылмады")
+		http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	newP := models.Product{
+		ID:          primitive.NewObjectID(),
+		Name:        r.FormValue("name"),
+		Price:       price,
+		Stock:       10,
+		City:        r.FormValue("city"),
+		CategoryID:  catID,
+		SellerID:    sellerID,
+		Description: r.FormValue("description"),
+		Attributes:  attrs,
+	}
+	app.DB.Products.InsertOne(r.Context(), newP)
+	app.DB.RecordPriceChange(newP.ID, newP.Price, models.PriceChangeCreated)
+	http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
+}
+
+// productAttributes validates the attr_* form fields against the schema of
+// the product's category. A category that does not exist gives
+// mongo.ErrNoDocuments.
+func (app *application) productAttributes(r *http.Request, categoryID primitive.ObjectID) (map[string]string, error) {
+	schema, err := app.DB.GetCategorySchema(categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for _, def := range schema {
+		values[def.Key] = r.PostFormValue("attr_" + def.Key)
+	}
+	return models.ValidateAttributes(schema, values)
+}
+
+func (app *application) deleteProduct(w http.ResponseWriter, r *http.Request) {
+	app.DB.DeleteProduct(r.FormValue("id"))
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (app *application) adminCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := app.DB.GetAllCategories()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "admin_categories.page.tmpl", &TemplateData{Categories: categories})
+}
+
+func categoryFromForm(r *http.Request) (models.Category, error) {
+	parentID, _ := primitive.ObjectIDFromHex(r.FormValue("parent_id"))
+	sortOrder, _ := strconv.Atoi(r.FormValue("sort_order"))
+
+	attrs, err := models.ParseAttributeDefs(r.FormValue("attributes"))
+
+	return models.Category{
+		Name:       r.FormValue("name"),
+		Slug:       r.FormValue("slug"),
+		ParentID:   parentID,
+		SortOrder:  sortOrder,
+		Attributes: attrs,
+	}, err
+}
+
+func (app *application) addCategory(w http.ResponseWriter, r *http.Request) {
+	c, err := categoryFromForm(r)
+	if err == nil {
+		err = app.DB.AddCategory(c)
+	}
+	if errors.Is(err, models.ErrInvalidCategory) {
+		app.session.Put(r.Context(), "flash", "Санат деректері қате: атауды, ата-санатты және сипаттамалар тізімін тексеріңіз")
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+func (app *application) updateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	c, err := categoryFromForm(r)
+	c.ID = id
+	if err == nil {
+		err = app.DB.UpdateCategory(c)
+	}
+	if errors.Is(err, models.ErrInvalidCategory) {
+		app.session.Put(r.Context(), "flash", "Санат деректері қате: ата-санатты және сипаттамалар тізімін тексеріңіз")
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+func (app *application) deleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	reassignTo, _ := primitive.ObjectIDFromHex(r.FormValue("reassign_to"))
+
+	err = app.DB.DeleteCategory(id, reassignTo)
+	switch {
+	case errors.Is(err, models.ErrCategoryInUse):
+		app.session.Put(r.Context(), "flash", "Санатта тауарлар бар: оларды көшіретін санатты таңдаңыз")
+	case errors.Is(err, models.ErrInvalidCategory):
+		app.session.Put(r.Context(), "flash", "Тауарларды көшіретін санат дұрыс емес")
+	case err != nil:
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	products, err := app.DB.GetAllProducts()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	revenue, err := app.DB.GetTotalRevenue()
+	if err != nil {
+		revenue = money.Money{}
+	}
+
+	totalOrders, err := app.DB.GetTotalOrderCount()
+	if err != nil {
+		totalOrders = 0
+	}
+
+	report, err := analytics.Admin(app.DB, analyticsRange(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.addDefaultData(&TemplateData{
+		Products:     products,
+		TotalRevenue: revenue,
+		TotalOrders:  int(totalOrders),
+		Analytics:    report,
+	}, r)
+
+	app.render(w, r, "admin_dashboard.page.tmpl", data)
+}
+
+func (app *application) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, _ := app.DB.GetAllUsers()
+	app.render(w, r, "admin_users.page.tmpl", &TemplateData{Users: users})
+}
+
+func (app *application) deleteUser(w http.ResponseWriter, r *http.Request) {
+	oid, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+	app.DB.DeleteUser(oid)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminOrders(w http.ResponseWriter, r *http.Request) {
+	orders, _ := app.DB.GetAllOrders()
+	app.render(w, r, "admin_orders.page.tmpl", &TemplateData{Orders: orders})
+}
+
+func (app *application) updateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	oid, _ := primitive.ObjectIDFromHex(r.FormValue("id"))
+	status := r.FormValue("status")
+
+	if status == "Cancelled" {
+		err := app.DB.CancelOrder(oid)
+		if err != nil && !errors.Is(err, models.ErrOrderNotCancellable) {
+			app.serverError(w, err)
+			return
+		}
+	} else {
+		app.DB.UpdateOrderStatus(oid, status)
+	}
+	http.Redirect(w, r, "/admin/orders", http.StatusSeeOther)
+}
+
+func (app *application) apiProducts(w http.ResponseWriter, r *http.Request) {
+	p, _ := app.DB.GetAllProducts()
+	json.NewEncoder(w).Encode(p)
+}
+
+func (app *application) apiListOrders(w http.ResponseWriter, r *http.Request) {
+	o, _ := app.DB.GetAllOrders()
+	json.NewEncoder(w).Encode(o)
+}
+
+func (app *application) showCart(w http.ResponseWriter, r *http.Request) {
+	cartItems, err := app.DB.GetCart(app.cartOwner(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	hasIssues, err := app.DB.ValidateCart(cartItems)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var subtotal money.Money
+	var lines []models.OrderItem
+	var inCart []primitive.ObjectID
+	for _, item := range cartItems {
+		inCart = append(inCart, item.ProductID)
+		if item.Issue == models.CartIssueUnavailable {
+			continue
+		}
+		item.Total = item.CurrentPrice.Mul(item.Quantity)
+		subtotal = subtotal.Add(item.Total)
+		lines = append(lines, models.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity, UnitPrice: item.CurrentPrice})
+	}
+
+	data := app.addDefaultData(&TemplateData{}, r)
+	data.Cart = &models.Cart{
+		Items:      cartItems,
+		Subtotal:   subtotal,
+		TotalPrice: subtotal,
+		HasIssues:  hasIssues,
+	}
+
+	if code := app.session.GetString(r.Context(), "couponCode"); code != "" {
+		uid, _ := primitive.ObjectIDFromHex(data.UserID)
+		data.Cart.CouponCode = code
+		_, discount, err := app.applyCoupon(code, uid, lines)
+		if msg, ok := couponErrorMessage(err); ok {
+			data.Cart.CouponError = msg
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Cart.Discount = discount
+		data.Cart.TotalPrice = subtotal.Sub(discount)
+	}
+
+	// The VAT is computed as at checkout, so that a rate added on top of the
+	// prices is part of the total before the order is placed.
+	preview := models.Order{Items: lines, TotalPrice: data.Cart.TotalPrice}
+	if err := app.DB.ApplyTax(app.tax, &preview); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Cart.Tax = preview.Tax
+	data.Cart.TaxInclusive = preview.TaxInclusive
+	data.Cart.TotalPrice = preview.TotalPrice
+
+	if data.UserRole == "customer" {
+		uid, _ := primitive.ObjectIDFromHex(data.UserID)
+		data.Wishlist, _ = app.DB.GetWishlist(uid)
+	}
+	data.Recommended, err = app.DB.GetCartRecommendations(inCart, 6)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	app.render(w, r, "cart.page.tmpl", data)
+}
+
+func (app *application) updateCartQuantity(w http.ResponseWriter, r *http.Request) {
+	product, err := app.DB.GetProduct(r.FormValue("product_id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	qty, err := strconv.Atoi(r.FormValue("quantity"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.DB.SetCartQuantity(app.cartOwner(r), product, qty)
+	if errors.Is(err, models.ErrOutOfStock) {
+		app.session.Put(r.Context(), "flash", "Бұл тауар қоймада қалмады")
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	} else if qty > product.Stock {
+		app.session.Put(r.Context(), "flash", fmt.Sprintf("Қоймада тек %d дана бар", product.Stock))
+	}
+
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+func (app *application) clearCart(w http.ResponseWriter, r *http.Request) {
+	err := app.DB.ClearCart(app.cartOwner(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+func (app *application) acknowledgeCart(w http.ResponseWriter, r *http.Request) {
+	err := app.DB.AcknowledgeCartChanges(app.cartOwner(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+func (app *application) removeFromCart(w http.ResponseWriter, r *http.Request) {
+	pid, err := primitive.ObjectIDFromHex(r.FormValue("product_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.DB.RemoveFromCart(app.cartOwner(r), pid)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+func (app *application) updateProductForm(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	product, err := app.DB.GetProduct(id)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	categories, _ := app.DB.GetAllCategories()
+	history, _ := app.DB.GetPriceHistory(product.ID)
+
+	app.render(w, r, "update_product.page.tmpl", &TemplateData{
+		Product:      product,
+		Categories:   categories,
+		PriceHistory: history,
+	})
+}
+
+func (app *application) updateProduct(w http.ResponseWriter, r *http.Request) {
+	idHex := r.FormValue("id")
+	oid, _ := primitive.ObjectIDFromHex(idHex)
+	price, _ := money.Parse(r.FormValue("price"))
+	catID, _ := primitive.ObjectIDFromHex(r.FormValue("category_id"))
+
+	attrs, err := app.productAttributes(r, catID)
+	var attrErr *models.AttributeError
+	if errors.As(err, &attrErr) {
+		app.session.Put(r.Context(), "flash", "Сипаттама толтырылмаған немесе қате: "+attrErr.Label)
+		http.Redirect(w, r, "/product/update?id="+idHex, http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		app.session.Put(r.Context(), "flash", "Санат табылмады")
+		http.Redirect(w, r, "/product/update?id="+idHex, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	updatedP := models.Product{
+		ID:          oid,
+		Name:        r.FormValue("name"),
+		Price:       price,
+		City:        r.FormValue("city"),
+		CategoryID:  catID,
+		Description: r.FormValue("description"),
+		Attributes:  attrs,
+	}
+
+	err = app.DB.UpdateProduct(updatedP)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Product updated successfully!")
+	http.Redirect(w, r, "/seller/dashboard", http.StatusSeeOther)
+}
+
+func (app *application) addToCart(w http.ResponseWriter, r *http.Request) {
+	product, err := app.DB.GetProduct(r.FormValue("product_id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
 	qty, _ := strconv.Atoi(r.FormValue("quantity"))
 	if qty <= 0 {
 		qty = 1
 	}
 
-	filter := bson.M{"user_id": uid, "product_id": pid}
-	update := bson.M{
-		"$set": bson.M{
-			"name":  product.Name,
-			"price": product.Price,
-		},
-		"$inc": bson.M{"quantity": qty},
+	err = app.DB.AddToCart(app.cartOwner(r), product, qty)
+	if errors.Is(err, models.ErrOutOfStock) {
+		app.session.Put(r.Context(), "flash", "Бұл тауар қоймада қалмады")
+		http.Redirect(w, r, "/product?id="+product.ID.Hex(), http.StatusSeeOther)
+		return
 	}
-	opts := options.Update().SetUpsert(true)
-
-	_, err = app.DB.Users.Database().Collection("cart").UpdateOne(r.Context(), filter, update, opts)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
+	app.events.Record(app.visitorID(r), events.Cart, product.ID)
 
 	http.Redirect(w, r, "/cart", http.StatusSeeOther)
 }
@@ -398,62 +1425,143 @@ func (app *application) createOrderFromCart(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	hasIssues, err := app.DB.ValidateCart(cartItems)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if hasIssues {
+		app.session.Put(r.Context(), "flash", "Себеттегі кейбір тауарлардың бағасы немесе қоры өзгерді. Өзгерістерді растаңыз.")
+		http.Redirect(w, r, "/cart", http.StatusSeeOther)
+		return
+	}
+
 	var orderItems []models.OrderItem
-	var total float64
+	var total money.Money
 
 	for _, item := range cartItems {
-		itemTotal := item.Price * float64(item.Quantity)
-
-		orderItems = append(orderItems, models.OrderItem{
+		orderItem := models.OrderItem{
 			ProductID: item.ProductID,
+			Name:      item.Name,
 			Quantity:  item.Quantity,
 			UnitPrice: item.Price,
-		})
-		total += itemTotal
+		}
+		orderItems = append(orderItems, orderItem)
+		total = total.Add(orderItem.Total())
 	}
 
 	order := models.Order{
 		ID:            primitive.NewObjectID(),
 		UserID:        userID,
 		Status:        "Pending",
+		Subtotal:      total,
 		TotalPrice:    total,
 		PaymentMethod: paymentMethod,
 		Items:         orderItems,
 		CreatedAt:     time.Now(),
 	}
 
-	err = app.DB.CreateOrder(order)
+	if code := app.session.GetString(r.Context(), "couponCode"); code != "" {
+		coupon, discount, err := app.applyCoupon(code, userID, order.Items)
+		if err == nil {
+			err = app.DB.RedeemCoupon(coupon.ID)
+		}
+		if msg, ok := couponErrorMessage(err); ok {
+			app.session.Remove(r.Context(), "couponCode")
+			app.session.Put(r.Context(), "flash", msg)
+			http.Redirect(w, r, "/cart", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		order.Discount = &models.OrderDiscount{CouponID: coupon.ID, Code: coupon.Code, Amount: discount}
+		order.TotalPrice = total.Sub(discount)
+	}
+
+	err = app.DB.ApplyTax(app.tax, &order)
+	if err == nil {
+		err = app.DB.CreateOrder(order)
+	}
 	if err != nil {
+		// The coupon use was taken for an order that does not exist.
+		if relErr := app.DB.ReleaseCoupon(order.Discount); relErr != nil {
+			app.errorLog.Printf("Release coupon of order %s: %v", order.ID.Hex(), relErr)
+		}
 		app.serverError(w, err)
 		return
 	}
 
-	_ = app.DB.ClearCart(userID)
+	_ = app.DB.ClearCart(models.CartOwner{UserID: userID})
+	app.session.Remove(r.Context(), "couponCode")
 
 	app.session.Put(r.Context(), "flash", "Заказ успешно оформлен!")
 	http.Redirect(w, r, "/orders", http.StatusSeeOther)
 }
 
 func (app *application) catalogPage(w http.ResponseWriter, r *http.Request) {
+	var category *models.Category
+	if oid, err := primitive.ObjectIDFromHex(r.URL.Query().Get("category")); err == nil {
+		category, _ = app.DB.GetCategory(oid)
+	}
+	app.renderCatalog(w, r, category)
+}
+
+func (app *application) categoryPage(w http.ResponseWriter, r *http.Request) {
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/c/"), "/")
+	category, err := app.DB.GetCategoryBySlug(slug)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	app.renderCatalog(w, r, category)
+}
+
+func (app *application) renderCatalog(w http.ResponseWriter, r *http.Request, category *models.Category) {
 	search := r.URL.Query().Get("search")
-	category := r.URL.Query().Get("category")
 	city := r.URL.Query().Get("city")
+	sort := r.URL.Query().Get("sort")
+	if city != "" {
+		app.session.Put(r.Context(), "city", city)
+	}
+
+	data := &TemplateData{
+		SearchTerm: search,
+		Sort:       sort,
+	}
+
+	attrs := make(map[string]string)
+	for key, values := range r.URL.Query() {
+		if name, ok := strings.CutPrefix(key, "attr_"); ok && len(values) > 0 && values[0] != "" {
+			attrs[name] = values[0]
+		}
+	}
+
+	categoryID := ""
+	if category != nil {
+		categoryID = category.ID.Hex()
+		data.CategoryID = categoryID
+		data.CategoryName = category.Name
+		data.Breadcrumbs, _ = app.DB.GetCategoryBreadcrumbs(category.ID)
 
-	products, err := app.DB.GetFilteredProducts(search, category, city)
+		schema, _ := app.DB.GetCategorySchema(category.ID)
+		ids, _ := app.DB.GetCategoryWithDescendants(category.ID)
+		data.Facets, _ = app.DB.GetAttributeFacets(search, city, ids, schema, attrs)
+	}
+
+	products, err := app.DB.GetFilteredProducts(search, categoryID, city, sort, attrs)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	categories, _ := app.DB.GetAllCategories()
-	cities, _ := app.DB.GetUniqueCities()
+	data.Products = products
+	data.Categories, _ = app.DB.GetAllCategories()
+	data.Cities, _ = app.DB.GetUniqueCities()
+	data.Wishlisted = app.wishlisted(r)
 
-	app.render(w, r, "catalog.page.tmpl", &TemplateData{
-		Products:   products,
-		Categories: categories,
-		Cities:     cities,
-		SearchTerm: search,
-	})
+	app.render(w, r, "catalog.page.tmpl", data)
 }
 
 func (app *application) showProduct(w http.ResponseWriter, r *http.Request) {
@@ -468,8 +1576,343 @@ func (app *application) showProduct(w http.ResponseWriter, r *http.Request) {
 	revs, _ := app.DB.GetReviews(p.ID)
 
 	data := app.addDefaultData(&TemplateData{}, r)
+	data.RecentlyViewed = app.recentlyViewed(r, p.ID, 8)
+	if app.session.GetString(r.Context(), "authenticatedUserID") != p.SellerID.Hex() {
+		app.events.Record(app.visitorID(r), events.View, p.ID)
+		app.recordRecentlyViewed(r, p.ID)
+	}
 	data.Product = p
 	data.Reviews = revs
+	data.ReportReasons = models.ReportReasons
+	data.Breadcrumbs, _ = app.DB.GetCategoryBreadcrumbs(p.CategoryID)
+	if schema, err := app.DB.GetCategorySchema(p.CategoryID); err == nil {
+		data.ProductAttributes = models.ProductAttributeValues(schema, p.Attributes)
+	}
+
+	data.AlsoBought, data.Similar, err = app.DB.GetRecommendations(p.ID, 8)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	if data.UserRole == "customer" {
+		uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+		data.CanReview, _ = app.DB.HasPurchased(uid, p.ID)
+		data.UserReview, _ = app.DB.GetUserReview(uid, p.ID)
+		data.Wishlisted = app.wishlisted(r)
+	}
 
 	app.render(w, r, "show.page.tmpl", data)
 }
+
+func (app *application) showWishlist(w http.ResponseWriter, r *http.Request) {
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	items, err := app.DB.GetWishlist(uid)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "wishlist.page.tmpl", &TemplateData{Wishlist: items})
+}
+
+func (app *application) addToWishlist(w http.ResponseWriter, r *http.Request) {
+	product, err := app.DB.GetProduct(r.FormValue("product_id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	err = app.DB.AddToWishlist(uid, product)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Тауар тілектер тізіміне қосылды")
+	app.redirectBack(w, r, "/wishlist")
+}
+
+func (app *application) removeFromWishlist(w http.ResponseWriter, r *http.Request) {
+	pid, err := primitive.ObjectIDFromHex(r.FormValue("product_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	err = app.DB.RemoveFromWishlist(uid, pid)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.redirectBack(w, r, "/wishlist")
+}
+
+func (app *application) moveToCart(w http.ResponseWriter, r *http.Request) {
+	product, err := app.DB.GetProduct(r.FormValue("product_id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	err = app.DB.MoveToCart(uid, product)
+	if errors.Is(err, models.ErrOutOfStock) {
+		app.session.Put(r.Context(), "flash", "Бұл тауар қоймада қалмады. Қайта түскенде хабарлаймыз.")
+		app.redirectBack(w, r, "/wishlist")
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+func (app *application) saveForLater(w http.ResponseWriter, r *http.Request) {
+	product, err := app.DB.GetProduct(r.FormValue("product_id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	err = app.DB.SaveForLater(uid, product)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+func (app *application) showNotifications(w http.ResponseWriter, r *http.Request) {
+	uid, _ := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+
+	notifications, err := app.DB.GetNotifications(uid)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Showing the list counts as reading it; the unread flags are kept on the
+	// rendered items so new ones can still be highlighted this time.
+	err = app.DB.MarkNotificationsRead(uid)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "notifications.page.tmpl", &TemplateData{Notifications: notifications})
+}
+
+func (app *application) applyCartCoupon(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeCouponCode(r.FormValue("code"))
+	if code == "" {
+		app.session.Remove(r.Context(), "couponCode")
+	} else {
+		app.session.Put(r.Context(), "couponCode", code)
+	}
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+func (app *application) removeCartCoupon(w http.ResponseWriter, r *http.Request) {
+	app.session.Remove(r.Context(), "couponCode")
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+// applyCoupon looks up the coupon by code and spreads its discount over the
+// order lines.
+func (app *application) applyCoupon(code string, userID primitive.ObjectID, items []models.OrderItem) (*models.Coupon, money.Money, error) {
+	coupon, err := app.DB.GetCouponByCode(code)
+	if err != nil {
+		return nil, money.Money{}, err
+	}
+	discount, err := app.DB.ApplyCoupon(coupon, userID, items)
+	return coupon, discount, err
+}
+
+// couponErrorMessage explains to the customer why a coupon was not accepted.
+// It reports false for errors that are not about the coupon itself.
+func couponErrorMessage(err error) (string, bool) {
+	switch {
+	case errors.Is(err, models.ErrCouponNotFound):
+		return "Мұндай промокод жоқ", true
+	case errors.Is(err, models.ErrCouponNotActive):
+		return "Промокод әзірге жарамсыз немесе мерзімі өтіп кеткен", true
+	case errors.Is(err, models.ErrCouponMinOrder):
+		return "Промокод үшін тапсырыс сомасы жеткіліксіз", true
+	case errors.Is(err, models.ErrCouponNotApplicable):
+		return "Промокод себеттегі тауарларға қолданылмайды", true
+	case errors.Is(err, models.ErrCouponUsedUp):
+		return "Промокодты қолдану шегі таусылды", true
+	}
+	return "", false
+}
+
+func (app *application) listCoupons(w http.ResponseWriter, r *http.Request) {
+	var sellerID primitive.ObjectID
+	if app.session.GetString(r.Context(), "userRole") == "seller" {
+		sellerID, _ = primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	}
+
+	coupons, err := app.DB.GetCoupons(sellerID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	categories, err := app.DB.GetAllCategories()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := &TemplateData{Coupons: coupons, Categories: categories}
+	if sellerID.IsZero() {
+		users, err := app.DB.GetAllUsers()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		for _, u := range users {
+			if u.Role == "seller" {
+				data.Users = append(data.Users, u)
+			}
+		}
+	}
+
+	app.render(w, r, "coupons.page.tmpl", data)
+}
+
+func (app *application) addCoupon(w http.ResponseWriter, r *http.Request) {
+	minOrder, _ := money.Parse(r.FormValue("min_order"))
+	maxUses, _ := strconv.Atoi(r.FormValue("max_uses"))
+	maxUsesPerUser, _ := strconv.Atoi(r.FormValue("max_uses_per_user"))
+	categoryID, _ := primitive.ObjectIDFromHex(r.FormValue("category_id"))
+	sellerID, _ := primitive.ObjectIDFromHex(r.FormValue("seller_id"))
+	startsAt, _ := time.ParseInLocation("2006-01-02T15:04", r.FormValue("starts_at"), time.Local)
+	endsAt, _ := time.ParseInLocation("2006-01-02T15:04", r.FormValue("ends_at"), time.Local)
+
+	// Sellers can only discount their own products.
+	if app.session.GetString(r.Context(), "userRole") == "seller" {
+		sellerID, _ = primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	}
+
+	coupon := models.Coupon{
+		Code:           r.FormValue("code"),
+		Type:           r.FormValue("type"),
+		MinOrder:       minOrder,
+		CategoryID:     categoryID,
+		SellerID:       sellerID,
+		MaxUses:        maxUses,
+		MaxUsesPerUser: maxUsesPerUser,
+		StartsAt:       startsAt,
+		EndsAt:         endsAt,
+	}
+	if coupon.Type == models.CouponFixed {
+		coupon.Amount, _ = money.Parse(r.FormValue("value"))
+	} else {
+		coupon.Value, _ = strconv.ParseFloat(r.FormValue("value"), 64)
+	}
+
+	err := app.DB.AddCoupon(coupon)
+	if errors.Is(err, models.ErrInvalidCoupon) {
+		app.session.Put(r.Context(), "flash", "Промокод деректері қате немесе мұндай код бар")
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/coupons", http.StatusSeeOther)
+}
+
+func (app *application) toggleCoupon(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	coupon, err := app.DB.GetCoupon(id)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	if app.session.GetString(r.Context(), "userRole") == "seller" && coupon.SellerID.Hex() != app.session.GetString(r.Context(), "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.DB.SetCouponActive(id, !coupon.Active)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/coupons", http.StatusSeeOther)
+}
+
+func (app *application) scheduleSale(w http.ResponseWriter, r *http.Request) {
+	product, err := app.DB.GetProduct(r.FormValue("product_id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	if !app.canManageProduct(r, product) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	price, _ := money.Parse(r.FormValue("sale_price"))
+	startsAt, err := time.ParseInLocation("2006-01-02T15:04", r.FormValue("starts_at"), time.Local)
+	if err != nil {
+		startsAt = time.Now()
+	}
+	endsAt, _ := time.ParseInLocation("2006-01-02T15:04", r.FormValue("ends_at"), time.Local)
+
+	err = app.DB.ScheduleSale(product.ID, models.Sale{Price: price, StartsAt: startsAt, EndsAt: endsAt})
+	switch {
+	case errors.Is(err, models.ErrSaleActive):
+		app.session.Put(r.Context(), "flash", "Тауарда жеңілдік жүріп жатыр. Жаңасын жоспарлау үшін алдымен оны тоқтатыңыз.")
+	case errors.Is(err, models.ErrInvalidSale):
+		app.session.Put(r.Context(), "flash", "Жеңілдік бағасы қазіргі бағадан төмен, ал аяқталу уақыты басталуынан кейін болуы керек")
+	case err != nil:
+		app.serverError(w, err)
+		return
+	default:
+		app.session.Put(r.Context(), "flash", "Жеңілдік жоспарланды")
+	}
+	http.Redirect(w, r, "/product/update?id="+product.ID.Hex(), http.StatusSeeOther)
+}
+
+func (app *application) cancelSale(w http.ResponseWriter, r *http.Request) {
+	product, err := app.DB.GetProduct(r.FormValue("product_id"))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	if !app.canManageProduct(r, product) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.DB.CancelSale(product.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/product/update?id="+product.ID.Hex(), http.StatusSeeOther)
+}
+
+// setCurrency remembers which currency the visitor wants approximate prices
+// in. Orders are always charged in tenge.
+func (app *application) setCurrency(w http.ResponseWriter, r *http.Request) {
+	currency := r.FormValue("currency")
+	if _, err := app.rates.Convert(money.Tenge(1), currency); err != nil {
+		currency = money.KZT
+	}
+	app.session.Put(r.Context(), "currency", currency)
+	app.redirectBack(w, r, "/")
+}
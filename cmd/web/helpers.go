@@ -1,11 +1,37 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"kazakh_aliexpress/internal/analytics"
+	"kazakh_aliexpress/internal/models"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxUploadSize  = 10 << 20
+	maxUploadFiles = 5
+	uploadDir      = "./ui/static/uploads"
 )
 
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
 func (app *application) serverError(w http.ResponseWriter, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
@@ -23,3 +49,200 @@ func (app *application) notFound(w http.ResponseWriter) {
 func (app *application) isAuthenticated(r *http.Request) bool {
 	return app.session.Exists(r.Context(), "authenticatedUserID")
 }
+
+// cartOwner returns the logged-in user's cart, or a guest cart tied to the
+// session for anonymous visitors.
+func (app *application) cartOwner(r *http.Request) models.CartOwner {
+	if uid, err := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID")); err == nil {
+		return models.CartOwner{UserID: uid}
+	}
+
+	guestID := app.session.GetString(r.Context(), "guestCartID")
+	if guestID == "" {
+		guestID = primitive.NewObjectID().Hex()
+		app.session.Put(r.Context(), "guestCartID", guestID)
+	}
+	return models.CartOwner{GuestID: guestID}
+}
+
+// visitorID identifies the session whose events are deduplicated: the user
+// when logged in, the guest cart otherwise.
+func (app *application) visitorID(r *http.Request) string {
+	owner := app.cartOwner(r)
+	if !owner.UserID.IsZero() {
+		return owner.UserID.Hex()
+	}
+	return owner.GuestID
+}
+
+// recordRecentlyViewed puts the product at the front of the browsing
+// history, kept in the database for users and in the session for guests.
+func (app *application) recordRecentlyViewed(r *http.Request, productID primitive.ObjectID) {
+	if uid, err := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID")); err == nil {
+		if err := app.DB.AddRecentlyViewed(uid, productID); err != nil {
+			app.errorLog.Print(err)
+		}
+		return
+	}
+
+	ids := []string{productID.Hex()}
+	for _, id := range strings.Split(app.session.GetString(r.Context(), "recentlyViewed"), ",") {
+		if id != "" && id != productID.Hex() && len(ids) < models.RecentlyViewedLimit {
+			ids = append(ids, id)
+		}
+	}
+	app.session.Put(r.Context(), "recentlyViewed", strings.Join(ids, ","))
+}
+
+// recentlyViewed returns up to limit products from the browsing history,
+// leaving out the one being shown.
+func (app *application) recentlyViewed(r *http.Request, exclude primitive.ObjectID, limit int) []*models.Product {
+	var ids []primitive.ObjectID
+	if uid, err := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID")); err == nil {
+		ids, err = app.DB.GetRecentlyViewedIDs(uid)
+		if err != nil {
+			app.errorLog.Print(err)
+			return nil
+		}
+	} else {
+		ids = parseObjectIDs(app.session.GetString(r.Context(), "recentlyViewed"))
+	}
+
+	shown := ids[:0:0]
+	for _, id := range ids {
+		if id != exclude && len(shown) < limit {
+			shown = append(shown, id)
+		}
+	}
+	products, err := app.DB.GetProductsByIDs(shown)
+	if err != nil {
+		app.errorLog.Print(err)
+		return nil
+	}
+	return products
+}
+
+// parseObjectIDs reads a comma separated list of hex ids, skipping invalid
+// ones.
+func parseObjectIDs(s string) []primitive.ObjectID {
+	var ids []primitive.ObjectID
+	for _, hex := range strings.Split(s, ",") {
+		if id, err := primitive.ObjectIDFromHex(hex); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// wishlisted returns the products in the customer's wishlist keyed by hex id,
+// so product cards can show whether a product is already saved.
+func (app *application) wishlisted(r *http.Request) map[string]bool {
+	if app.session.GetString(r.Context(), "userRole") != "customer" {
+		return nil
+	}
+	uid, err := primitive.ObjectIDFromHex(app.session.GetString(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		return nil
+	}
+	ids, _ := app.DB.GetWishlistedIDs(uid)
+	return ids
+}
+
+// redirectBack sends the user to the page the form was posted from, falling
+// back to the given path when the referer is missing or points elsewhere.
+func (app *application) redirectBack(w http.ResponseWriter, r *http.Request, fallback string) {
+	target := fallback
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		target = ref.RequestURI()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (app *application) canManageProduct(r *http.Request, product *models.Product) bool {
+	if app.session.GetString(r.Context(), "userRole") == "admin" {
+		return true
+	}
+	return product.SellerID.Hex() == app.session.GetString(r.Context(), "authenticatedUserID")
+}
+
+func (app *application) canAccessOrder(r *http.Request, order *models.Order) bool {
+	if app.session.GetString(r.Context(), "userRole") == "admin" {
+		return true
+	}
+	return order.UserID.Hex() == app.session.GetString(r.Context(), "authenticatedUserID")
+}
+
+// saveUploads stores uploaded images under ui/static/uploads/<dir> and
+// returns their public URLs.
+func (app *application) saveUploads(files []*multipart.FileHeader, dir string) ([]string, error) {
+	if len(files) > maxUploadFiles {
+		return nil, errors.New("too many files")
+	}
+
+	target := filepath.Join(uploadDir, dir)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, fh := range files {
+		url, err := saveUpload(fh, target, dir)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+func saveUpload(fh *multipart.FileHeader, target, dir string) (string, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(src, head)
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return "", fmt.Errorf("unsupported file type %s", contentType)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	name := primitive.NewObjectID().Hex() + ext
+
+	dst, err := os.Create(filepath.Join(target, name))
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", err
+	}
+	return "/static/uploads/" + dir + "/" + name, nil
+}
+
+func (app *application) servePDF(w http.ResponseWriter, filename string, data []byte) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// analyticsRange reads the reporting period from the query string: either
+// from/to dates or "days" counted back from today.
+func analyticsRange(r *http.Request) analytics.Range {
+	q := r.URL.Query()
+	from, to := q.Get("from"), q.Get("to")
+	now := time.Now()
+	if days, err := strconv.Atoi(q.Get("days")); err == nil && days > 0 {
+		from = now.In(analytics.Location).AddDate(0, 0, 1-days).Format("2006-01-02")
+		to = ""
+	}
+	return analytics.ParseRange(from, to, q.Get("granularity"), now)
+}
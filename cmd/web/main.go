@@ -2,12 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"html/template"
+	"kazakh_aliexpress/internal/events"
+	"kazakh_aliexpress/internal/feeds"
 	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/moderation"
+	"kazakh_aliexpress/internal/money"
+	"kazakh_aliexpress/internal/pdf"
 	"kazakh_aliexpress/internal/repository"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
@@ -24,9 +35,19 @@ type application struct {
 	infoLog        *log.Logger
 	errorLog       *log.Logger
 	templateCache  map[string]*template.Template
+	reviewFilter   *moderation.Filter
+	rates          money.Rates
+	tax            models.TaxRule
+	pdfFont        *pdf.Font
+	events         *events.Recorder
+	feeds          *feeds.Cache
 }
 
 func main() {
+	recomputeRatings := flag.Bool("recompute-ratings", false, "Recompute rating summaries of all products and exit")
+	migrateMoney := flag.Bool("migrate-money", false, "Convert stored float amounts to integer tiyn and exit")
+	flag.Parse()
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
@@ -36,7 +57,45 @@ func main() {
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	templateCache, err := newTemplateCache()
+	rates, err := money.LoadRates(os.Getenv("CURRENCY_RATES_FILE"))
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	templateCache, err := newTemplateCache(rates)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	tax := models.KazakhstanVAT
+	if v := os.Getenv("VAT_RATE"); v != "" {
+		tax.Rate, err = strconv.ParseFloat(v, 64)
+		if err != nil || tax.Rate < 0 {
+			errorLog.Fatal("Invalid VAT_RATE: ", v)
+		}
+	}
+	if v := os.Getenv("VAT_INCLUSIVE"); v != "" {
+		tax.Inclusive, err = strconv.ParseBool(v)
+		if err != nil {
+			errorLog.Fatal("Invalid VAT_INCLUSIVE: ", err)
+		}
+	}
+
+	// Without the default font PDF documents are left out, but a font that
+	// was asked for explicitly has to load.
+	fontPath := os.Getenv("PDF_FONT_FILE")
+	if fontPath == "" {
+		fontPath = "./ui/fonts/DejaVuSans.ttf"
+	}
+	pdfFont, err := pdf.LoadFont(fontPath)
+	if err != nil {
+		if os.Getenv("PDF_FONT_FILE") != "" {
+			errorLog.Fatalf("PDF_FONT_FILE: %v", err)
+		}
+		errorLog.Printf("PDF documents are disabled: %v", err)
+	}
+
+	reviewFilter, err := moderation.LoadFilter(os.Getenv("REVIEW_WORDLISTS_DIR"))
 	if err != nil {
 		errorLog.Fatal(err)
 	}
@@ -55,25 +114,91 @@ func main() {
 	db := client.Database("kazakh_aliexpress")
 
 	app := &application{
-		DB: &models.MongoDB{
-			Products:   db.Collection("products"),
-			Reviews:    db.Collection("reviews"),
-			Users:      db.Collection("users"),
-			Orders:     db.Collection("orders"),
-			Categories: db.Collection("categories"),
-			Payments:   db.Collection("payments"),
-		},
+		DB:            models.NewMongoDB(db),
 		session:       session,
 		orderQueue:    make(chan models.Order, 20),
 		infoLog:       infoLog,
 		errorLog:      errorLog,
 		templateCache: templateCache,
+		reviewFilter:  reviewFilter,
+		rates:         rates,
+		tax:           tax,
+		pdfFont:       pdfFont,
 		UserRepository: &repository.UserRepository{
 			Collection: db.Collection("users"),
 		},
 	}
 
+	applied, err := app.DB.Migrate()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	for _, name := range applied {
+		infoLog.Printf("Applied migration %s", name)
+	}
+	// The site works without indexes, only slower, so a failure is no reason
+	// to stay down.
+	if err := app.DB.EnsureIndexes(); err != nil {
+		errorLog.Println("Failed to create indexes:", err)
+	}
+
+	app.events = events.NewRecorder(app.DB)
+
+	siteURL := os.Getenv("SITE_URL")
+	if siteURL == "" {
+		siteURL = "http://localhost:8080"
+	}
+	app.feeds = feeds.NewCache(app.DB, "Kazakh@Express", strings.TrimSuffix(siteURL, "/"))
+
+	if *recomputeRatings {
+		n, err := app.DB.RecomputeAllRatings()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Recomputed ratings for %d products", n)
+		return
+	}
+
+	if *migrateMoney {
+		n, err := app.DB.MigrateMoney()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Converted amounts in %d documents", n)
+		return
+	}
+
+	cartExpiry := 7 * 24 * time.Hour
+	if v := os.Getenv("CART_EXPIRY"); v != "" {
+		cartExpiry, err = time.ParseDuration(v)
+		if err != nil {
+			errorLog.Fatal("Invalid CART_EXPIRY: ", err)
+		}
+	}
+
+	// Imports interrupted by a restart start over; applying rows twice is
+	// harmless.
+	running, err := app.DB.GetRunningImports()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	for _, imp := range running {
+		go app.runImport(imp)
+	}
+
 	go app.orderWorker()
+	go app.cartExpiryWorker(cartExpiry, time.Hour)
+	go app.wishlistWatcher(15 * time.Minute)
+	go app.saleScheduler(time.Minute)
+	flushCtx, stopFlusher := context.WithCancel(context.Background())
+	flusherDone := make(chan struct{})
+	go func() {
+		app.eventFlusher(flushCtx, 30*time.Second)
+		close(flusherDone)
+	}()
+	go app.popularityUpdater(time.Hour)
+	go app.recommendationBuilder(6 * time.Hour)
+	go app.feedBuilder(time.Hour)
 
 	srv := &http.Server{
 		Addr:         ":8080",
@@ -83,6 +208,25 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	infoLog.Println("Server running on http://localhost:8080")
-	errorLog.Fatal(srv.ListenAndServe())
+	go func() {
+		infoLog.Println("Server running on http://localhost:8080")
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errorLog.Fatal(err)
+		}
+	}()
+
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-stop.Done()
+
+	// Requests still running record events, so the server stops first and the
+	// last events are flushed after it.
+	infoLog.Println("Shutting down")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errorLog.Println("Shutdown:", err)
+	}
+	stopFlusher()
+	<-flusherDone
 }
@@ -8,38 +8,83 @@ func (app *application) routes() http.Handler {
 
 	mux.Handle("/", dynamic(http.HandlerFunc(app.home)))
 	mux.Handle("/catalog", dynamic(http.HandlerFunc(app.catalogPage)))
+	mux.Handle("/c/", dynamic(http.HandlerFunc(app.categoryPage)))
 	mux.Handle("/product", dynamic(http.HandlerFunc(app.showProduct)))
 	mux.Handle("/login", dynamic(http.HandlerFunc(app.loginUser)))
 	mux.Handle("/register", dynamic(http.HandlerFunc(app.register)))
 	mux.Handle("/logout", dynamic(http.HandlerFunc(app.logoutUser)))
+	mux.Handle("/currency", dynamic(http.HandlerFunc(app.setCurrency)))
+	mux.Handle("/profile", dynamic(app.requireAuthentication(http.HandlerFunc(app.showProfile))))
+	mux.Handle("/recently-viewed/clear", dynamic(http.HandlerFunc(app.clearRecentlyViewed)))
 
-	mux.Handle("/cart", dynamic(app.requireAuthentication(http.HandlerFunc(app.showCart))))
-	mux.Handle("/cart/add", dynamic(app.requireAuthentication(http.HandlerFunc(app.addToCart))))
-	mux.Handle("/cart/remove", dynamic(app.requireAuthentication(http.HandlerFunc(app.removeFromCart))))
+	mux.Handle("/cart", dynamic(http.HandlerFunc(app.showCart)))
+	mux.Handle("/cart/add", dynamic(http.HandlerFunc(app.addToCart)))
+	mux.Handle("/cart/remove", dynamic(http.HandlerFunc(app.removeFromCart)))
+	mux.Handle("/cart/update", dynamic(http.HandlerFunc(app.updateCartQuantity)))
+	mux.Handle("/cart/clear", dynamic(http.HandlerFunc(app.clearCart)))
+	mux.Handle("/cart/acknowledge", dynamic(http.HandlerFunc(app.acknowledgeCart)))
+	mux.Handle("/cart/coupon", dynamic(http.HandlerFunc(app.applyCartCoupon)))
+	mux.Handle("/cart/coupon/remove", dynamic(http.HandlerFunc(app.removeCartCoupon)))
 	mux.Handle("/order/create-from-cart", dynamic(app.requireAuthentication(http.HandlerFunc(app.createOrderFromCart))))
 
 	mux.Handle("/orders", dynamic(app.requireAuthentication(app.requireRole([]string{"customer", "admin"}, http.HandlerFunc(app.listOrdersPage)))))
 	mux.Handle("/order", dynamic(app.requireAuthentication(app.requireRole([]string{"customer", "admin"}, http.HandlerFunc(app.showOrder)))))
+	mux.Handle("/order/invoice", dynamic(app.requireAuthentication(app.requireRole([]string{"customer", "admin"}, http.HandlerFunc(app.downloadInvoice)))))
+	mux.Handle("/order/receipt", dynamic(app.requireAuthentication(app.requireRole([]string{"customer", "admin"}, http.HandlerFunc(app.showReceipt)))))
 	mux.Handle("/order/create", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.createOrderFromCart)))))
 	mux.Handle("/payment/complete", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.completePayment)))))
+	mux.Handle("/order/cancel", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.cancelOrder)))))
+	mux.Handle("/order/return", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.requestReturn)))))
+	mux.Handle("/wishlist", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.showWishlist)))))
+	mux.Handle("/wishlist/add", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.addToWishlist)))))
+	mux.Handle("/wishlist/remove", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.removeFromWishlist)))))
+	mux.Handle("/wishlist/move-to-cart", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.moveToCart)))))
+	mux.Handle("/cart/save-for-later", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.saveForLater)))))
+	mux.Handle("/notifications", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.showNotifications)))))
 	mux.Handle("/review/add", dynamic(app.requireAuthentication(app.requireRole([]string{"customer"}, http.HandlerFunc(app.addReview)))))
+	mux.Handle("/review/delete", dynamic(app.requireAuthentication(app.requireRole([]string{"customer", "admin"}, http.HandlerFunc(app.deleteReview)))))
+	mux.Handle("/review/report", dynamic(app.requireAuthentication(http.HandlerFunc(app.reportReview))))
+	mux.Handle("/review/reply", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.replyToReview)))))
 
 	mux.Handle("/seller/dashboard", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.sellerDashboard)))))
+	mux.Handle("/seller/packing-slip", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.downloadPackingSlip)))))
+	mux.Handle("/seller/analytics.csv", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.sellerAnalyticsCSV)))))
+	mux.Handle("/seller/statement", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.downloadStatement)))))
+	mux.Handle("/seller/import", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.sellerImport)))))
+	mux.Handle("/seller/import/upload", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.uploadImport)))))
+	mux.Handle("/seller/import/preview", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.previewImport)))))
+	mux.Handle("/seller/import/start", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.startImport)))))
+	mux.Handle("/seller/export", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.exportProducts)))))
+	mux.Handle("/seller/profile", dynamic(app.requireAuthentication(app.requireRole([]string{"seller"}, http.HandlerFunc(app.updateSellerProfile)))))
 	mux.Handle("/product/create", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.createProduct)))))
 	mux.Handle("/product/delete", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.deleteProduct)))))
 	mux.Handle("/product/update", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.updateProductForm)))))
 	mux.Handle("/product/update/save", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.updateProduct)))))
-	mux.Handle("/category/add", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.addCategory)))))
+	mux.Handle("/product/sale", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.scheduleSale)))))
+	mux.Handle("/product/sale/cancel", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.cancelSale)))))
+	mux.Handle("/coupons", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.listCoupons)))))
+	mux.Handle("/coupon/add", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.addCoupon)))))
+	mux.Handle("/coupon/toggle", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.toggleCoupon)))))
+	mux.Handle("/returns", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.listReturns)))))
+	mux.Handle("/returns/resolve", dynamic(app.requireAuthentication(app.requireRole([]string{"seller", "admin"}, http.HandlerFunc(app.resolveReturn)))))
 
 	mux.Handle("/admin/dashboard", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.adminDashboard)))))
 	mux.Handle("/admin/users", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.listUsers)))))
 	mux.Handle("/admin/users/delete", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.deleteUser)))))
 	mux.Handle("/admin/orders", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.adminOrders)))))
 	mux.Handle("/admin/orders/update", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.updateOrderStatus)))))
+	mux.Handle("/admin/categories", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.adminCategories)))))
+	mux.Handle("/category/add", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.addCategory)))))
+	mux.Handle("/category/update", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.updateCategory)))))
+	mux.Handle("/category/delete", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.deleteCategory)))))
+	mux.Handle("/admin/reviews", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.adminReviews)))))
+	mux.Handle("/admin/reviews/moderate", dynamic(app.requireAuthentication(app.requireRole([]string{"admin"}, http.HandlerFunc(app.moderateReview)))))
 
 	mux.HandleFunc("/api/products", app.apiProducts)
 	mux.HandleFunc("/api/orders", app.apiListOrders)
 
+	mux.HandleFunc("/feeds/", app.productFeed)
+
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/static/", http.StripPrefix("/static/", fileServer))
 
@@ -2,32 +2,92 @@ package main
 
 import (
 	"html/template"
+	"kazakh_aliexpress/internal/analytics"
 	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/money"
 	"path/filepath"
 )
 
 type TemplateData struct {
-	IsAuthenticated bool
-	UserRole        string
-	UserName        string
-	Products        []*models.Product
-	Product         *models.Product
-	Reviews         []*models.Review
-	Orders          []*models.Order
-	Order           *models.Order
-	Cart            *models.Cart
-	Payment         *models.Payment
-	Users           []*models.User
-	Categories      []*models.Category
-	SearchTerm      string
-	CategoryName    string
-	TotalRevenue    float64
-	TotalOrders     int
-	Cities          []string
-	CurrentYear     int
+	IsAuthenticated     bool
+	UserID              string
+	UserRole            string
+	UserName            string
+	Flash               string
+	Products            []*models.Product
+	Product             *models.Product
+	Reviews             []*models.Review
+	UserReview          *models.Review
+	CanReview           bool
+	Orders              []*models.Order
+	Order               *models.Order
+	Cart                *models.Cart
+	Wishlist            []*models.WishlistItem
+	Wishlisted          map[string]bool
+	Notifications       []*models.Notification
+	Coupons             []*models.Coupon
+	PriceHistory        []*models.PriceChange
+	UnreadNotifications int64
+	Payment             *models.Payment
+	Receipt             *models.Invoice
+	User                *models.User
+	Returns             []*models.ReturnRequest
+	Users               []*models.User
+	Categories          []*models.Category
+	SearchTerm          string
+	Sort                string
+	CategoryID          string
+	CategoryName        string
+	Breadcrumbs         []*models.Category
+	Facets              []models.Facet
+	ProductAttributes   []models.AttributeValue
+	Analytics           *analytics.Report
+	SellerAnalytics     *analytics.SellerReport
+	Trending            []*models.Product
+	City                string
+	AlsoBought          []*models.Product
+	Similar             []*models.Product
+	Recommended         []*models.Product
+	RecentlyViewed      []*models.Product
+	Import              *models.Import
+	Imports             []*models.Import
+	ImportFields        []models.ImportField
+	ReportReasons       []models.ReportReason
+	TotalRevenue        money.Money
+	TotalOrders         int
+	Cities              []string
+	CurrentYear         int
+	Currency            string
+	Currencies          []string
+	Documents           bool
+	Month               string
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
+// Carousel is a titled row of product cards for the "product-carousel"
+// partial, which has no access to the page's display currency otherwise.
+type Carousel struct {
+	Title    string
+	Products []*models.Product
+	Currency string
+}
+
+func (td *TemplateData) Carousel(title string, products []*models.Product) Carousel {
+	return Carousel{Title: title, Products: products, Currency: td.Currency}
+}
+
+// templateFuncs formats amounts in templates. "money" prints an amount as is,
+// "price" also shows its approximate value in the currency the visitor chose:
+// {{price .Price $.Currency}}.
+func templateFuncs(rates money.Rates) template.FuncMap {
+	return template.FuncMap{
+		"money": func(m money.Money) string {
+			return m.String()
+		},
+		"price": rates.Display,
+	}
+}
+
+func newTemplateCache(rates money.Rates) (map[string]*template.Template, error) {
 	cache := make(map[string]*template.Template)
 
 	pages, err := filepath.Glob("./ui/html/*.page.tmpl")
@@ -38,7 +98,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	for _, page := range pages {
 		name := filepath.Base(page)
 
-		ts, err := template.ParseFiles("./ui/html/base.layout.tmpl")
+		ts, err := template.New(name).Funcs(templateFuncs(rates)).ParseFiles("./ui/html/base.layout.tmpl")
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,320 @@
+// Package analytics aggregates orders and users into time series and
+// rankings for the admin and seller dashboards.
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Buckets are cut at midnight Kazakhstan time.
+var Location = models.Location
+
+const mongoTimezone = "+05:00"
+
+const (
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+)
+
+// maxBuckets keeps charts readable: longer ranges switch to coarser buckets.
+const maxBuckets = 120
+
+// Range is the reporting period [From, To) and the bucket size.
+type Range struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+}
+
+// ParseRange reads the dates of a range form (YYYY-MM-DD, both inclusive).
+// Missing or invalid values fall back to the last 30 days by day.
+func ParseRange(from, to, granularity string, now time.Time) Range {
+	now = now.In(Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, Location)
+
+	r := Range{From: today.AddDate(0, 0, -29), To: today.AddDate(0, 0, 1), Granularity: granularity}
+	if t, err := time.ParseInLocation("2006-01-02", to, Location); err == nil {
+		r.To = t.AddDate(0, 0, 1)
+	}
+	if t, err := time.ParseInLocation("2006-01-02", from, Location); err == nil && t.Before(r.To) {
+		r.From = t
+	} else if !r.From.Before(r.To) {
+		r.From = r.To.AddDate(0, 0, -30)
+	}
+
+	if r.Granularity != Week && r.Granularity != Month {
+		r.Granularity = Day
+	}
+	for r.Granularity != Month && len(r.buckets()) > maxBuckets {
+		if r.Granularity == Day {
+			r.Granularity = Week
+		} else {
+			r.Granularity = Month
+		}
+	}
+	return r
+}
+
+// FromDate and ToDate format the range for date inputs.
+func (r Range) FromDate() string {
+	return r.From.Format("2006-01-02")
+}
+
+func (r Range) ToDate() string {
+	return r.To.AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+func (r Range) truncate(t time.Time) time.Time {
+	t = t.In(Location)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Location)
+	switch r.Granularity {
+	case Week:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, Location)
+	}
+	return day
+}
+
+func (r Range) next(t time.Time) time.Time {
+	switch r.Granularity {
+	case Week:
+		return t.AddDate(0, 0, 7)
+	case Month:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// buckets lists the start of every bucket in the range.
+func (r Range) buckets() []time.Time {
+	var starts []time.Time
+	for t := r.truncate(r.From); t.Before(r.To); t = r.next(t) {
+		starts = append(starts, t)
+	}
+	return starts
+}
+
+func (r Range) label(t time.Time) string {
+	if r.Granularity == Month {
+		return t.Format("01.2006")
+	}
+	return t.Format("02.01")
+}
+
+// dateTrunc groups dates the same way truncate does, on the database side.
+func (r Range) dateTrunc(field string) bson.M {
+	trunc := bson.M{"date": field, "unit": r.Granularity, "timezone": mongoTimezone}
+	if r.Granularity == Week {
+		trunc["startOfWeek"] = "monday"
+	}
+	return bson.M{"$dateTrunc": trunc}
+}
+
+// Point is one bucket of a time series.
+type Point struct {
+	Start   time.Time
+	Label   string
+	Revenue money.Money
+	Orders  int64
+	Count   int64
+}
+
+// Top is a row of a ranking.
+type Top struct {
+	ID      primitive.ObjectID
+	Name    string
+	Units   int64
+	Orders  int64
+	Revenue money.Money
+}
+
+// Report is the admin overview of a period.
+type Report struct {
+	Range         Range
+	Series        []Point
+	NewUsers      []Point
+	Revenue       money.Money
+	Orders        int64
+	AverageOrder  money.Money
+	NewUserCount  int64
+	TopProducts   []Top
+	TopCategories []Top
+	TopCities     []Top
+}
+
+// soldStatuses are the order states that count as sales.
+var soldStatuses = bson.A{"Paid", "Processing", "Shipped", "Delivered"}
+
+// lineRevenue is what the customer paid for an order line.
+var lineRevenue = bson.M{"$subtract": bson.A{
+	bson.M{"$multiply": bson.A{"$items.unitprice.amount", "$items.quantity"}},
+	bson.M{"$ifNull": bson.A{"$items.discount.amount", 0}},
+}}
+
+const topLimit = 10
+
+func Admin(db *models.MongoDB, r Range) (*Report, error) {
+	rep := &Report{Range: r}
+	match := bson.M{"created_at": bson.M{"$gte": r.From, "$lt": r.To}, "status": bson.M{"$in": soldStatuses}}
+
+	var series []struct {
+		Start   time.Time `bson:"_id"`
+		Revenue int64     `bson:"revenue"`
+		Orders  int64     `bson:"orders"`
+	}
+	err := aggregate(db.Orders, &series, bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{
+			"_id":     r.dateTrunc("$created_at"),
+			"revenue": bson.M{"$sum": "$total_price.amount"},
+			"orders":  bson.M{"$sum": 1},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byStart := map[int64]Point{}
+	for _, s := range series {
+		byStart[s.Start.Unix()] = Point{Revenue: money.FromMinor(s.Revenue), Orders: s.Orders}
+		rep.Revenue = rep.Revenue.Add(money.FromMinor(s.Revenue))
+		rep.Orders += s.Orders
+	}
+	rep.Series = r.fill(byStart)
+	rep.AverageOrder = rep.Revenue.Share(1, rep.Orders)
+
+	var users []struct {
+		Start time.Time `bson:"_id"`
+		Count int64     `bson:"count"`
+	}
+	err = aggregate(db.Users, &users, bson.A{
+		bson.M{"$match": bson.M{"created_at": bson.M{"$gte": r.From, "$lt": r.To}}},
+		bson.M{"$group": bson.M{"_id": r.dateTrunc("$created_at"), "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	byStart = map[int64]Point{}
+	for _, u := range users {
+		byStart[u.Start.Unix()] = Point{Count: u.Count}
+		rep.NewUserCount += u.Count
+	}
+	rep.NewUsers = r.fill(byStart)
+
+	if err := rep.rankings(db, match); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// rankings fills the top products, categories and cities in one pass over
+// the order lines.
+func (rep *Report) rankings(db *models.MongoDB, match bson.M) error {
+	group := func(key interface{}, name interface{}) bson.A {
+		return bson.A{
+			bson.M{"$group": bson.M{
+				"_id":     key,
+				"name":    bson.M{"$first": name},
+				"units":   bson.M{"$sum": "$items.quantity"},
+				"orders":  bson.M{"$addToSet": "$_id"},
+				"revenue": bson.M{"$sum": lineRevenue},
+			}},
+			bson.M{"$set": bson.M{"orders": bson.M{"$size": "$orders"}}},
+			bson.M{"$sort": bson.D{{Key: "revenue", Value: -1}, {Key: "units", Value: -1}}},
+			bson.M{"$limit": topLimit},
+		}
+	}
+
+	type row struct {
+		ID      interface{} `bson:"_id"`
+		Name    string      `bson:"name"`
+		Units   int64       `bson:"units"`
+		Orders  int64       `bson:"orders"`
+		Revenue int64       `bson:"revenue"`
+	}
+	var facets []struct {
+		Products   []row `bson:"products"`
+		Categories []row `bson:"categories"`
+		Cities     []row `bson:"cities"`
+	}
+	err := aggregate(db.Orders, &facets, bson.A{
+		bson.M{"$match": match},
+		bson.M{"$unwind": "$items"},
+		bson.M{"$lookup": bson.M{
+			"from":         db.Products.Name(),
+			"localField":   "items.product_id",
+			"foreignField": "_id",
+			"as":           "product",
+		}},
+		bson.M{"$unwind": bson.M{"path": "$product", "preserveNullAndEmptyArrays": true}},
+		bson.M{"$facet": bson.M{
+			"products":   group("$items.product_id", "$items.name"),
+			"categories": append(bson.A{bson.M{"$match": bson.M{"product.category_id": bson.M{"$exists": true}}}}, group("$product.category_id", "")...),
+			"cities":     append(bson.A{bson.M{"$match": bson.M{"product.city": bson.M{"$nin": bson.A{nil, ""}}}}}, group("$product.city", "$product.city")...),
+		}},
+	})
+	if err != nil || len(facets) == 0 {
+		return err
+	}
+
+	categories, err := db.GetAllCategories()
+	if err != nil {
+		return err
+	}
+	categoryNames := map[primitive.ObjectID]string{}
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+
+	convert := func(rows []row) []Top {
+		tops := make([]Top, 0, len(rows))
+		for _, r := range rows {
+			t := Top{Name: r.Name, Units: r.Units, Orders: r.Orders, Revenue: money.FromMinor(r.Revenue)}
+			if id, ok := r.ID.(primitive.ObjectID); ok {
+				t.ID = id
+			}
+			tops = append(tops, t)
+		}
+		return tops
+	}
+	rep.TopProducts = convert(facets[0].Products)
+	rep.TopCategories = convert(facets[0].Categories)
+	for i := range rep.TopCategories {
+		rep.TopCategories[i].Name = categoryNames[rep.TopCategories[i].ID]
+	}
+	rep.TopCities = convert(facets[0].Cities)
+	return nil
+}
+
+// fill lays the aggregated buckets over the full range so that days without
+// sales show up as zero.
+func (r Range) fill(byStart map[int64]Point) []Point {
+	starts := r.buckets()
+	points := make([]Point, len(starts))
+	for i, start := range starts {
+		p := byStart[start.Unix()]
+		p.Start = start
+		p.Label = r.label(start)
+		points[i] = p
+	}
+	return points
+}
+
+func aggregate(coll *mongo.Collection, results interface{}, pipeline bson.A) error {
+	cur, err := coll.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.TODO())
+	return cur.All(context.TODO(), results)
+}
@@ -0,0 +1,154 @@
+package analytics
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Charts are drawn as inline SVG on the server, so the dashboards need no
+// JavaScript. Hovering a point or bar shows its exact value.
+const (
+	chartWidth  = 720.0
+	chartHeight = 220.0
+	chartLeft   = 56.0
+	chartRight  = 10.0
+	chartTop    = 12.0
+	chartBottom = 28.0
+	maxXLabels  = 12
+)
+
+type chartPoint struct {
+	label string
+	value float64
+	title string
+}
+
+func lineChart(points []chartPoint, color, name string) template.HTML {
+	return chart(points, color, name, false)
+}
+
+func barChart(points []chartPoint, color, name string) template.HTML {
+	return chart(points, color, name, true)
+}
+
+func chart(points []chartPoint, color, name string, bars bool) template.HTML {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %g %g" width="100%%" role="img" aria-label="%s" style="font: 10px sans-serif; max-width: %gpx;">`,
+		chartWidth, chartHeight, template.HTMLEscapeString(name), chartWidth)
+
+	top := niceMax(points)
+	plotW := chartWidth - chartLeft - chartRight
+	plotH := chartHeight - chartTop - chartBottom
+	y := func(v float64) float64 { return chartTop + plotH*(1-v/top) }
+
+	for i := 0; i <= 4; i++ {
+		v := top * float64(i) / 4
+		fmt.Fprintf(&b, `<line x1="%g" y1="%.1f" x2="%g" y2="%.1f" stroke="#eee"/>`, chartLeft, y(v), chartWidth-chartRight, y(v))
+		fmt.Fprintf(&b, `<text x="%g" y="%.1f" text-anchor="end" fill="#888">%s</text>`, chartLeft-6, y(v)+3, compact(v))
+	}
+
+	n := len(points)
+	if n == 0 {
+		b.WriteString(`</svg>`)
+		return template.HTML(b.String())
+	}
+	slot := plotW / float64(n)
+	x := func(i int) float64 { return chartLeft + slot*(float64(i)+0.5) }
+
+	every := (n + maxXLabels - 1) / maxXLabels
+	for i, p := range points {
+		if i%every == 0 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%g" text-anchor="middle" fill="#888">%s</text>`, x(i), chartHeight-10, template.HTMLEscapeString(p.label))
+		}
+	}
+
+	if bars {
+		w := math.Max(slot*0.7, 1)
+		for i, p := range points {
+			fmt.Fprintf(&b, `<g><title>%s</title><rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/></g>`,
+				template.HTMLEscapeString(p.title), x(i)-w/2, y(p.value), w, y(0)-y(p.value), color)
+		}
+	} else {
+		coords := make([]string, n)
+		for i, p := range points {
+			coords[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(p.value))
+		}
+		fmt.Fprintf(&b, `<polygon points="%.1f,%.1f %s %.1f,%.1f" fill="%s" fill-opacity="0.12"/>`,
+			x(0), y(0), strings.Join(coords, " "), x(n-1), y(0), color)
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(coords, " "), color)
+		for i, p := range points {
+			fmt.Fprintf(&b, `<g><title>%s</title><circle cx="%.1f" cy="%.1f" r="3" fill="%s"/></g>`,
+				template.HTMLEscapeString(p.title), x(i), y(p.value), color)
+		}
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// niceMax rounds the largest value up to 1, 2 or 5 times a power of ten so
+// that the grid lines get round labels.
+func niceMax(points []chartPoint) float64 {
+	var top float64
+	for _, p := range points {
+		top = math.Max(top, p.value)
+	}
+	if top <= 0 {
+		return 4
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(top)))
+	for _, step := range []float64{1, 2, 4, 5, 10} {
+		if top <= step*magnitude {
+			return math.Max(step*magnitude, 4)
+		}
+	}
+	return 10 * magnitude
+}
+
+// compact formats an axis value: 950, 12 мың, 1,5 млн.
+func compact(v float64) string {
+	switch {
+	case v >= 1e6:
+		return trimFloat(v/1e6) + " млн"
+	case v >= 1e3:
+		return trimFloat(v/1e3) + " мың"
+	}
+	return trimFloat(v)
+}
+
+func trimFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 1, 64)
+	s = strings.TrimSuffix(s, ".0")
+	return strings.Replace(s, ".", ",", 1)
+}
+
+func revenueChart(series []Point) template.HTML {
+	points := make([]chartPoint, len(series))
+	for i, p := range series {
+		points[i] = chartPoint{p.Label, p.Revenue.Major(), p.Label + ": " + p.Revenue.String()}
+	}
+	return lineChart(points, "#28a745", "Табыс")
+}
+
+func (rep *Report) RevenueChart() template.HTML {
+	return revenueChart(rep.Series)
+}
+
+func (rep *Report) OrdersChart() template.HTML {
+	points := make([]chartPoint, len(rep.Series))
+	for i, p := range rep.Series {
+		points[i] = chartPoint{p.Label, float64(p.Orders), fmt.Sprintf("%s: %d тапсырыс", p.Label, p.Orders)}
+	}
+	return barChart(points, "#00afca", "Тапсырыстар")
+}
+
+func (rep *Report) NewUsersChart() template.HTML {
+	points := make([]chartPoint, len(rep.NewUsers))
+	for i, p := range rep.NewUsers {
+		points[i] = chartPoint{p.Label, float64(p.Count), fmt.Sprintf("%s: %d пайдаланушы", p.Label, p.Count)}
+	}
+	return barChart(points, "#fcd116", "Жаңа пайдаланушылар")
+}
@@ -0,0 +1,234 @@
+package analytics
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProductStats is one product's performance in a seller report.
+type ProductStats struct {
+	ID          primitive.ObjectID
+	Name        string
+	Units       int64
+	Orders      int64
+	Revenue     money.Money
+	Views       int64
+	Returned    int64
+	RatingAvg   float64
+	RatingCount int
+}
+
+// Conversion is the share of views that ended in an order, in percent.
+func (p ProductStats) Conversion() float64 {
+	return percent(p.Orders, p.Views)
+}
+
+// ReturnRate is the share of sold units that were returned, in percent.
+func (p ProductStats) ReturnRate() float64 {
+	return percent(p.Returned, p.Units)
+}
+
+// SellerReport is a seller's view of their own sales in a period.
+type SellerReport struct {
+	Range       Range
+	Series      []Point
+	Revenue     money.Money
+	Units       int64
+	Orders      int64
+	Views       int64
+	Returned    int64
+	RatingAvg   float64
+	RatingCount int
+	Products    []ProductStats
+}
+
+func (rep *SellerReport) Conversion() float64 {
+	return percent(rep.Orders, rep.Views)
+}
+
+func (rep *SellerReport) ReturnRate() float64 {
+	return percent(rep.Returned, rep.Units)
+}
+
+func percent(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
+// Seller builds the report from the seller's lines in sold orders, product
+// views, return requests and the rating summaries of their products. Lines
+// of older orders carry no seller and are matched by product.
+func Seller(db *models.MongoDB, sellerID primitive.ObjectID, r Range) (*SellerReport, error) {
+	rep := &SellerReport{Range: r}
+
+	products, err := db.GetProductsBySeller(sellerID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(products))
+	stats := map[primitive.ObjectID]*ProductStats{}
+	for _, p := range products {
+		ids = append(ids, p.ID)
+		stats[p.ID] = &ProductStats{ID: p.ID, Name: p.Name, RatingAvg: p.RatingAvg, RatingCount: p.RatingCount}
+		rep.RatingAvg += p.RatingAvg * float64(p.RatingCount)
+		rep.RatingCount += p.RatingCount
+	}
+	if rep.RatingCount > 0 {
+		rep.RatingAvg /= float64(rep.RatingCount)
+	}
+
+	ownLine := bson.M{"$or": bson.A{
+		bson.M{"items.seller_id": sellerID},
+		bson.M{"items.seller_id": bson.M{"$exists": false}, "items.product_id": bson.M{"$in": ids}},
+	}}
+
+	var facets []struct {
+		Series []struct {
+			Start   time.Time `bson:"_id"`
+			Revenue int64     `bson:"revenue"`
+			Units   int64     `bson:"units"`
+			Orders  int64     `bson:"orders"`
+		} `bson:"series"`
+		Products []struct {
+			ID      primitive.ObjectID `bson:"_id"`
+			Name    string             `bson:"name"`
+			Units   int64              `bson:"units"`
+			Orders  int64              `bson:"orders"`
+			Revenue int64              `bson:"revenue"`
+		} `bson:"products"`
+		Orders []struct {
+			Count int64 `bson:"count"`
+		} `bson:"orders"`
+	}
+	err = aggregate(db.Orders, &facets, bson.A{
+		bson.M{"$match": bson.M{
+			"created_at": bson.M{"$gte": r.From, "$lt": r.To},
+			"status":     bson.M{"$in": soldStatuses},
+			"$or": bson.A{
+				bson.M{"items.seller_id": sellerID},
+				bson.M{"items.product_id": bson.M{"$in": ids}},
+			},
+		}},
+		bson.M{"$unwind": "$items"},
+		bson.M{"$match": ownLine},
+		bson.M{"$facet": bson.M{
+			"series": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":     r.dateTrunc("$created_at"),
+					"revenue": bson.M{"$sum": lineRevenue},
+					"units":   bson.M{"$sum": "$items.quantity"},
+					"orders":  bson.M{"$addToSet": "$_id"},
+				}},
+				bson.M{"$set": bson.M{"orders": bson.M{"$size": "$orders"}}},
+			},
+			"products": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":     "$items.product_id",
+					"name":    bson.M{"$first": "$items.name"},
+					"units":   bson.M{"$sum": "$items.quantity"},
+					"orders":  bson.M{"$addToSet": "$_id"},
+					"revenue": bson.M{"$sum": lineRevenue},
+				}},
+				bson.M{"$set": bson.M{"orders": bson.M{"$size": "$orders"}}},
+			},
+			"orders": bson.A{
+				bson.M{"$group": bson.M{"_id": "$_id"}},
+				bson.M{"$count": "count"},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byStart := map[int64]Point{}
+	if len(facets) > 0 {
+		f := facets[0]
+		for _, s := range f.Series {
+			byStart[s.Start.Unix()] = Point{Revenue: money.FromMinor(s.Revenue), Orders: s.Orders, Count: s.Units}
+			rep.Revenue = rep.Revenue.Add(money.FromMinor(s.Revenue))
+			rep.Units += s.Units
+		}
+		for _, p := range f.Products {
+			st, ok := stats[p.ID]
+			if !ok {
+				// Sold before the product was deleted.
+				st = &ProductStats{ID: p.ID, Name: p.Name}
+				stats[p.ID] = st
+			}
+			st.Units, st.Orders, st.Revenue = p.Units, p.Orders, money.FromMinor(p.Revenue)
+		}
+		if len(f.Orders) > 0 {
+			rep.Orders = f.Orders[0].Count
+		}
+	}
+	rep.Series = r.fill(byStart)
+
+	views, err := db.GetProductViews(ids, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	for id, v := range views {
+		stats[id].Views = v
+		rep.Views += v
+	}
+
+	var returns []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Units int64              `bson:"units"`
+	}
+	err = aggregate(db.Returns, &returns, bson.A{
+		bson.M{"$match": bson.M{
+			"seller_id":  sellerID,
+			"status":     bson.M{"$ne": "Rejected"},
+			"created_at": bson.M{"$gte": r.From, "$lt": r.To},
+		}},
+		bson.M{"$group": bson.M{"_id": "$product_id", "units": bson.M{"$sum": "$quantity"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, ret := range returns {
+		if st, ok := stats[ret.ID]; ok {
+			st.Returned = ret.Units
+		}
+		rep.Returned += ret.Units
+	}
+
+	for _, st := range stats {
+		rep.Products = append(rep.Products, *st)
+	}
+	sort.Slice(rep.Products, func(i, j int) bool {
+		a, b := rep.Products[i], rep.Products[j]
+		if a.Revenue.Amount != b.Revenue.Amount {
+			return b.Revenue.Less(a.Revenue)
+		}
+		if a.Views != b.Views {
+			return a.Views > b.Views
+		}
+		return a.Name < b.Name
+	})
+	return rep, nil
+}
+
+func (rep *SellerReport) RevenueChart() template.HTML {
+	return revenueChart(rep.Series)
+}
+
+func (rep *SellerReport) UnitsChart() template.HTML {
+	points := make([]chartPoint, len(rep.Series))
+	for i, p := range rep.Series {
+		points[i] = chartPoint{p.Label, float64(p.Count), fmt.Sprintf("%s: %d дана", p.Label, p.Count)}
+	}
+	return barChart(points, "#00afca", "Сатылған тауарлар")
+}
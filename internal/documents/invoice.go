@@ -0,0 +1,131 @@
+package documents
+
+import (
+	"fmt"
+	"strconv"
+
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/money"
+	"kazakh_aliexpress/internal/pdf"
+)
+
+const dateFormat = "02.01.2006"
+
+// Invoice renders the customer's invoice for an order. Paid orders show the
+// payment and serve as the receipt.
+func Invoice(font *pdf.Font, inv *models.Invoice) ([]byte, error) {
+	o := inv.Order
+	l := newLayout(font, "Шот № "+o.ID.Hex())
+
+	l.title("Шот № " + o.ID.Hex())
+	l.field("Күні", o.CreatedAt.Format(dateFormat+" 15:04"))
+	l.field("Сатып алушы", inv.Buyer.Email)
+	l.field("Төлем әдісі", paymentMethod(o.PaymentMethod))
+	if inv.Payment != nil && inv.Payment.Status == "Paid" {
+		l.field("Төленді", inv.Payment.CreatedAt.Format(dateFormat+" 15:04"))
+	} else {
+		l.field("Күйі", "төлем күтілуде")
+	}
+
+	cols := []column{
+		{title: "№", width: 0.05},
+		{title: "Тауар", width: 0.35},
+		{title: "Саны", width: 0.08, right: true},
+		{title: "Бағасы", width: 0.14, right: true},
+		{title: "Жеңілдік", width: 0.12, right: true},
+		{title: "ҚҚС", width: 0.12, right: true},
+		{title: "Сомасы", width: 0.14, right: true},
+	}
+	for _, g := range inv.Sellers {
+		l.space(6)
+		sellerDetails(l, g.Seller)
+		l.space(4)
+
+		rows := make([][]string, 0, len(g.Items))
+		for i, item := range g.Items {
+			rows = append(rows, []string{
+				strconv.Itoa(i + 1),
+				item.Name,
+				strconv.Itoa(item.Quantity),
+				item.UnitPrice.String(),
+				dash(item.Discount),
+				lineTax(item),
+				item.Total().Sub(item.Discount).String(),
+			})
+		}
+		l.table(cols, rows)
+		l.total("Сатушы бойынша", g.Total.String(), false)
+		if g.Tax.IsPositive() {
+			l.total(taxLabel(o), g.Tax.String(), false)
+		}
+	}
+
+	l.rule()
+	l.total("Тауарлар сомасы", o.Subtotal.String(), false)
+	if o.Discount != nil {
+		l.total("Промокод "+o.Discount.Code, "-"+o.Discount.Amount.String(), false)
+	}
+	if o.Tax.IsPositive() {
+		l.total(taxLabel(o), o.Tax.String(), false)
+	}
+	l.total("Барлығы", o.TotalPrice.String(), true)
+
+	return l.finish("Kazakh@Express — шот № " + o.ID.Hex())
+}
+
+func sellerDetails(l *layout, seller *models.User) {
+	info := seller.Seller
+	if info == nil {
+		l.heading("Сатушы: " + seller.Email)
+		l.text("ҚҚС төлеушісі емес")
+		return
+	}
+
+	name := info.CompanyName
+	if name == "" {
+		name = seller.Email
+	}
+	l.heading("Сатушы: " + name)
+	if info.BIN != "" {
+		l.text("БСН: " + info.BIN)
+	}
+	if info.Address != "" {
+		l.text("Мекенжайы: " + info.Address)
+	}
+	if info.VATRegistered {
+		l.text("ҚҚС төлеушісі")
+	} else {
+		l.text("ҚҚС төлеушісі емес")
+	}
+}
+
+func paymentMethod(method string) string {
+	switch method {
+	case "Credit Card":
+		return "Банк картасы"
+	case "Cash on Delivery":
+		return "Курьерге қолма-қол ақша"
+	}
+	return method
+}
+
+func taxLabel(o *models.Order) string {
+	if o.TaxInclusive {
+		return "Оның ішінде ҚҚС"
+	}
+	return "ҚҚС"
+}
+
+func lineTax(item models.OrderItem) string {
+	if !item.Tax.IsPositive() {
+		return "ҚҚС-сыз"
+	}
+	return fmt.Sprintf("%g%% %s", item.TaxRate, item.Tax.String())
+}
+
+func dash(m money.Money) string {
+	if m.IsZero() {
+		return "—"
+	}
+	return "-" + m.String()
+}
@@ -0,0 +1,179 @@
+// Package documents renders order invoices, packing slips and seller
+// statements as PDF.
+package documents
+
+import (
+	"fmt"
+
+	"kazakh_aliexpress/internal/pdf"
+)
+
+const (
+	marginX      = 45.0
+	marginTop    = 50.0
+	marginBottom = 60.0
+	contentWidth = pdf.A4Width - 2*marginX
+
+	textSize  = 9.5
+	lineGap   = 4.0
+	cellInset = 4.0
+)
+
+// layout writes flowing content top to bottom, starting new pages as needed.
+type layout struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64
+}
+
+func newLayout(font *pdf.Font, title string) *layout {
+	l := &layout{doc: pdf.New(font, title)}
+	l.newPage()
+	return l
+}
+
+func (l *layout) newPage() {
+	l.page = l.doc.AddPage()
+	l.y = marginTop
+}
+
+// ensure starts a new page unless h more points fit on the current one.
+func (l *layout) ensure(h float64) {
+	if l.y+h > pdf.A4Height-marginBottom {
+		l.newPage()
+	}
+}
+
+func (l *layout) space(h float64) {
+	l.y += h
+}
+
+func (l *layout) title(s string) {
+	l.ensure(30)
+	l.y += 16
+	l.page.BoldText(marginX, l.y, 16, s)
+	l.y += 12
+}
+
+func (l *layout) heading(s string) {
+	l.ensure(30)
+	l.y += 14
+	l.page.BoldText(marginX, l.y, 11, s)
+	l.y += 6
+}
+
+// text writes a wrapped paragraph.
+func (l *layout) text(s string) {
+	font := l.doc.Font()
+	for _, line := range font.Wrap(s, textSize, contentWidth) {
+		l.ensure(textSize + lineGap)
+		l.y += textSize + lineGap
+		l.page.Text(marginX, l.y, textSize, line)
+	}
+}
+
+// field writes a "label: value" line with the label in bold.
+func (l *layout) field(label, value string) {
+	font := l.doc.Font()
+	l.ensure(textSize + lineGap)
+	l.y += textSize + lineGap
+	l.page.BoldText(marginX, l.y, textSize, label+":")
+	x := marginX + font.Width(label+": ", textSize)
+	l.page.Text(x, l.y, textSize, value)
+}
+
+// total writes a right aligned summary line.
+func (l *layout) total(label, value string, bold bool) {
+	l.ensure(textSize + lineGap)
+	l.y += textSize + lineGap
+	s := label + ": " + value
+	if bold {
+		x := pdf.A4Width - marginX - l.doc.Font().Width(s, textSize)
+		l.page.BoldText(x, l.y, textSize, s)
+		return
+	}
+	l.page.TextRight(pdf.A4Width-marginX, l.y, textSize, s)
+}
+
+func (l *layout) rule() {
+	l.y += 6
+	l.page.Line(marginX, l.y, pdf.A4Width-marginX, l.y, 0.5)
+}
+
+type column struct {
+	title string
+	width float64 // share of the content width
+	right bool
+}
+
+// table draws rows with a shaded header. Cells wrap and the header is
+// repeated when the table continues on the next page.
+func (l *layout) table(cols []column, rows [][]string) {
+	font := l.doc.Font()
+	widths := make([]float64, len(cols))
+	for i, c := range cols {
+		widths[i] = c.width * contentWidth
+	}
+
+	header := func() {
+		h := textSize + 2*cellInset
+		l.ensure(h * 2)
+		l.page.FillRect(marginX, l.y, contentWidth, h, 0.9)
+		x := marginX
+		for i, c := range cols {
+			l.cell(x, l.y+cellInset+textSize-1, widths[i], c.title, c.right, true)
+			x += widths[i]
+		}
+		l.y += h
+	}
+	header()
+
+	for _, row := range rows {
+		cells := make([][]string, len(cols))
+		lines := 1
+		for i := range cols {
+			cells[i] = font.Wrap(row[i], textSize, widths[i]-2*cellInset)
+			lines = max(lines, len(cells[i]))
+		}
+		h := float64(lines)*(textSize+lineGap) + 2*cellInset - lineGap
+		if l.y+h > pdf.A4Height-marginBottom {
+			l.newPage()
+			header()
+		}
+
+		x := marginX
+		for i := range cols {
+			for n, line := range cells[i] {
+				baseline := l.y + cellInset + textSize - 1 + float64(n)*(textSize+lineGap)
+				l.cell(x, baseline, widths[i], line, cols[i].right, false)
+			}
+			x += widths[i]
+		}
+		l.y += h
+		l.page.Line(marginX, l.y, pdf.A4Width-marginX, l.y, 0.3)
+	}
+}
+
+func (l *layout) cell(x, baseline, width float64, s string, right, bold bool) {
+	if right {
+		x += width - cellInset - l.doc.Font().Width(s, textSize)
+	} else {
+		x += cellInset
+	}
+	if bold {
+		l.page.BoldText(x, baseline, textSize, s)
+		return
+	}
+	l.page.Text(x, baseline, textSize, s)
+}
+
+// finish numbers the pages and renders the document.
+func (l *layout) finish(footer string) ([]byte, error) {
+	pages := l.doc.Pages()
+	for i, p := range pages {
+		y := pdf.A4Height - marginBottom/2
+		p.Text(marginX, y, 8, footer)
+		p.TextRight(pdf.A4Width-marginX, y, 8, fmt.Sprintf("%d / %d бет", i+1, len(pages)))
+	}
+	return l.doc.Bytes()
+}
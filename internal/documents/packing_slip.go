@@ -0,0 +1,48 @@
+package documents
+
+import (
+	"strconv"
+
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/pdf"
+)
+
+// PackingSlip renders a packing slip for each seller group, one per page, so
+// that every seller packs only their own lines.
+func PackingSlip(font *pdf.Font, inv *models.Invoice, groups []*models.SellerLines) ([]byte, error) {
+	o := inv.Order
+	l := newLayout(font, "Жүк парағы № "+o.ID.Hex())
+
+	cols := []column{
+		{title: "№", width: 0.06},
+		{title: "Тауар", width: 0.46},
+		{title: "Тауар коды", width: 0.28},
+		{title: "Саны", width: 0.1, right: true},
+		{title: "Белгі", width: 0.1},
+	}
+	for n, g := range groups {
+		if n > 0 {
+			l.newPage()
+		}
+		l.title("Жүк парағы № " + o.ID.Hex())
+		l.field("Тапсырыс күні", o.CreatedAt.Format(dateFormat))
+		l.field("Алушы", inv.Buyer.Email)
+		l.space(4)
+		sellerDetails(l, g.Seller)
+		l.space(6)
+
+		var units int
+		rows := make([][]string, 0, len(g.Items))
+		for i, item := range g.Items {
+			rows = append(rows, []string{strconv.Itoa(i + 1), item.Name, item.ProductID.Hex(), strconv.Itoa(item.Quantity), "[   ]"})
+			units += item.Quantity
+		}
+		l.table(cols, rows)
+		l.total("Барлығы", strconv.Itoa(units)+" дана", true)
+
+		l.space(30)
+		l.text("Жинаған: ____________________        Тексерген: ____________________")
+	}
+
+	return l.finish("Kazakh@Express — жүк парағы № " + o.ID.Hex())
+}
@@ -0,0 +1,82 @@
+package documents
+
+import (
+	"strconv"
+
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/pdf"
+)
+
+// Statement renders a seller's monthly statement.
+func Statement(font *pdf.Font, st *models.Statement) ([]byte, error) {
+	period := st.From.Format(dateFormat) + " – " + st.To.AddDate(0, 0, -1).Format(dateFormat)
+	l := newLayout(font, "Ай сайынғы есеп "+st.From.Format("01.2006"))
+
+	l.title("Сатушының ай сайынғы есебі")
+	l.field("Кезең", period)
+	sellerDetails(l, st.Seller)
+
+	l.heading("Сатылымдар")
+	if len(st.Lines) == 0 {
+		l.text("Бұл айда сатылым болған жоқ.")
+	} else {
+		rows := make([][]string, 0, len(st.Lines))
+		for _, line := range st.Lines {
+			rows = append(rows, []string{
+				line.Date.Format(dateFormat),
+				shortID(line.OrderID.Hex()),
+				line.Name,
+				strconv.Itoa(line.Quantity),
+				line.Amount.String(),
+				dash(line.Discount),
+				line.Tax.String(),
+			})
+		}
+		l.space(4)
+		l.table([]column{
+			{title: "Күні", width: 0.12},
+			{title: "Тапсырыс", width: 0.12},
+			{title: "Тауар", width: 0.3},
+			{title: "Саны", width: 0.07, right: true},
+			{title: "Сомасы", width: 0.14, right: true},
+			{title: "Жеңілдік", width: 0.13, right: true},
+			{title: "ҚҚС", width: 0.12, right: true},
+		}, rows)
+	}
+
+	if len(st.Refunds) > 0 {
+		l.heading("Қайтарылған тауарлар")
+		rows := make([][]string, 0, len(st.Refunds))
+		for _, rr := range st.Refunds {
+			rows = append(rows, []string{
+				rr.ResolvedAt.Format(dateFormat),
+				shortID(rr.OrderID.Hex()),
+				rr.Name,
+				strconv.Itoa(rr.Quantity),
+				rr.Amount.String(),
+			})
+		}
+		l.space(4)
+		l.table([]column{
+			{title: "Күні", width: 0.12},
+			{title: "Тапсырыс", width: 0.12},
+			{title: "Тауар", width: 0.45},
+			{title: "Саны", width: 0.11, right: true},
+			{title: "Сомасы", width: 0.2, right: true},
+		}, rows)
+	}
+
+	l.rule()
+	l.total("Сатылым сомасы", st.Sales.String(), false)
+	l.total("Жеңілдіктер", "-"+st.Discounts.String(), false)
+	l.total("ҚҚС", st.Tax.String(), false)
+	l.total("Қайтарылды", "-"+st.Refunded.String(), false)
+	l.total("Ай бойынша түсім", st.Net.String(), true)
+
+	return l.finish("Kazakh@Express — сатушы есебі, " + period)
+}
+
+// shortID is the tail of an order ID, enough to find the order.
+func shortID(hex string) string {
+	return "…" + hex[len(hex)-8:]
+}
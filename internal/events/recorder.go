@@ -0,0 +1,126 @@
+// Package events counts what shoppers do with products. Events are kept in
+// memory and written to the daily product statistics in batches, so
+// recording one costs a request nothing but a map update.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"kazakh_aliexpress/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Kind int
+
+const (
+	View Kind = iota
+	Cart
+	Purchase
+)
+
+// dedupWindow is how long repeated events of one session are ignored, so
+// that reloading a page or adding the same product twice counts once.
+const dedupWindow = 30 * time.Minute
+
+type key struct {
+	product primitive.ObjectID
+	day     time.Time
+}
+
+type seenKey struct {
+	session string
+	kind    Kind
+	product primitive.ObjectID
+}
+
+type Recorder struct {
+	db *models.MongoDB
+
+	mu      sync.Mutex
+	pending map[key]*models.ProductDay
+	seen    map[seenKey]time.Time
+}
+
+func NewRecorder(db *models.MongoDB) *Recorder {
+	return &Recorder{
+		db:      db,
+		pending: map[key]*models.ProductDay{},
+		seen:    map[seenKey]time.Time{},
+	}
+}
+
+// Record counts an event of the session unless the session had the same one
+// recently.
+func (rec *Recorder) Record(session string, kind Kind, productID primitive.ObjectID) {
+	now := time.Now()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	sk := seenKey{session, kind, productID}
+	if t, ok := rec.seen[sk]; ok && now.Sub(t) < dedupWindow {
+		return
+	}
+	rec.seen[sk] = now
+
+	k := key{productID, models.Day(now)}
+	d, ok := rec.pending[k]
+	if !ok {
+		d = &models.ProductDay{ProductID: productID, Day: k.day}
+		rec.pending[k] = d
+	}
+	switch kind {
+	case View:
+		d.Views++
+	case Cart:
+		d.Carts++
+	case Purchase:
+		d.Purchases++
+	}
+}
+
+// Flush writes the pending counters. If writing fails they are kept and
+// retried with the next flush.
+func (rec *Recorder) Flush() (int, error) {
+	rec.mu.Lock()
+	days := make([]models.ProductDay, 0, len(rec.pending))
+	for _, d := range rec.pending {
+		days = append(days, *d)
+	}
+	rec.pending = map[key]*models.ProductDay{}
+
+	now := time.Now()
+	for sk, t := range rec.seen {
+		if now.Sub(t) >= dedupWindow {
+			delete(rec.seen, sk)
+		}
+	}
+	rec.mu.Unlock()
+
+	for i, d := range days {
+		if err := rec.db.AddProductStats(d); err != nil {
+			rec.restore(days[i:])
+			return i, err
+		}
+	}
+	return len(days), nil
+}
+
+func (rec *Recorder) restore(days []models.ProductDay) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	for _, d := range days {
+		k := key{d.ProductID, d.Day}
+		p, ok := rec.pending[k]
+		if !ok {
+			p = &models.ProductDay{ProductID: d.ProductID, Day: d.Day}
+			rec.pending[k] = p
+		}
+		p.Views += d.Views
+		p.Carts += d.Carts
+		p.Purchases += d.Purchases
+	}
+}
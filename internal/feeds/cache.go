@@ -0,0 +1,120 @@
+package feeds
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"kazakh_aliexpress/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Feed is a rendered feed document.
+type Feed struct {
+	Data      []byte
+	Generated time.Time
+}
+
+type feedKey struct {
+	format string
+	seller primitive.ObjectID
+}
+
+// Cache keeps the rendered feeds of the marketplace and of every seller with
+// products. Rebuild replaces all of them at once.
+type Cache struct {
+	db      *models.MongoDB
+	name    string
+	siteURL string
+
+	mu    sync.RWMutex
+	feeds map[feedKey]*Feed
+}
+
+// NewCache creates an empty cache. Product links in the feeds start with
+// siteURL, the public address of the site.
+func NewCache(db *models.MongoDB, name, siteURL string) *Cache {
+	return &Cache{db: db, name: name, siteURL: siteURL, feeds: map[feedKey]*Feed{}}
+}
+
+// Get returns the marketplace feed for a zero seller ID, otherwise the
+// seller's feed. It reports false until the feed has been built.
+func (c *Cache) Get(format string, sellerID primitive.ObjectID) (*Feed, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	f, ok := c.feeds[feedKey{format, sellerID}]
+	return f, ok
+}
+
+// Rebuild renders all feeds from the current catalog and returns how many
+// it built.
+func (c *Cache) Rebuild() (int, error) {
+	products, err := c.db.GetAllProducts()
+	if err != nil {
+		return 0, err
+	}
+	categories, err := c.db.GetAllCategories()
+	if err != nil {
+		return 0, err
+	}
+
+	bySeller := map[primitive.ObjectID][]*models.Product{}
+	for _, p := range products {
+		bySeller[p.SellerID] = append(bySeller[p.SellerID], p)
+	}
+
+	shops := map[primitive.ObjectID]*Shop{
+		primitive.NilObjectID: {Name: c.name, Company: c.name, URL: c.siteURL, Categories: categories, Products: products},
+	}
+	for sellerID, list := range bySeller {
+		shop := &Shop{Name: c.name, Company: c.name, URL: c.siteURL, Categories: usedCategories(categories, list), Products: list}
+		if u, err := c.db.GetUser(sellerID); err == nil && u.Seller != nil && u.Seller.CompanyName != "" {
+			shop.Name = u.Seller.CompanyName
+			shop.Company = u.Seller.CompanyName
+		}
+		shops[sellerID] = shop
+	}
+
+	now := time.Now()
+	feeds := make(map[feedKey]*Feed, len(shops)*len(Formats))
+	for sellerID, shop := range shops {
+		for _, format := range Formats {
+			var buf bytes.Buffer
+			if err := Write(format, &buf, shop, now); err != nil {
+				return 0, err
+			}
+			feeds[feedKey{format, sellerID}] = &Feed{Data: buf.Bytes(), Generated: now}
+		}
+	}
+
+	c.mu.Lock()
+	c.feeds = feeds
+	c.mu.Unlock()
+	return len(feeds), nil
+}
+
+// usedCategories keeps the categories of the products and their ancestors,
+// in tree order.
+func usedCategories(all []*models.Category, products []*models.Product) []*models.Category {
+	direct := map[primitive.ObjectID]bool{}
+	for _, p := range products {
+		direct[p.CategoryID] = true
+	}
+	used := map[primitive.ObjectID]bool{}
+	for _, cat := range all {
+		if direct[cat.ID] {
+			used[cat.ID] = true
+			for _, id := range cat.Path {
+				used[id] = true
+			}
+		}
+	}
+	var out []*models.Category
+	for _, cat := range all {
+		if used[cat.ID] {
+			out = append(out, cat)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+// Package feeds renders the catalog as product feeds for price aggregators:
+// Yandex YML and Google Merchant RSS. Feeds are built in the background and
+// served from memory.
+package feeds
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"kazakh_aliexpress/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrUnknownFormat = errors.New("feeds: unknown format")
+
+const (
+	YML    = "yml"
+	Google = "google"
+)
+
+var Formats = []string{YML, Google}
+
+// Shop is what a feed describes: the whole marketplace or one seller.
+type Shop struct {
+	Name       string
+	Company    string
+	URL        string
+	Categories []*models.Category
+	Products   []*models.Product
+}
+
+func (s *Shop) productURL(p *models.Product) string {
+	return s.URL + "/product?id=" + p.ID.Hex()
+}
+
+// categoryPaths maps category IDs to the names of the category and its
+// ancestors, "Электроника > Телефондар".
+func (s *Shop) categoryPaths() map[primitive.ObjectID]string {
+	names := make(map[primitive.ObjectID]string, len(s.Categories))
+	for _, c := range s.Categories {
+		names[c.ID] = c.Name
+	}
+	paths := make(map[primitive.ObjectID]string, len(s.Categories))
+	for _, c := range s.Categories {
+		var parts []string
+		for _, id := range c.Path {
+			if name, ok := names[id]; ok {
+				parts = append(parts, name)
+			}
+		}
+		paths[c.ID] = strings.Join(append(parts, c.Name), " > ")
+	}
+	return paths
+}
+
+// Write renders the shop in the given format.
+func Write(format string, w io.Writer, s *Shop, generated time.Time) error {
+	var doc interface{}
+	switch format {
+	case YML:
+		doc = ymlCatalog(s, generated)
+	case Google:
+		doc = googleFeed(s)
+	default:
+		return ErrUnknownFormat
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
@@ -0,0 +1,169 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"kazakh_aliexpress/internal/models"
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testShop() *Shop {
+	electronics := &models.Category{ID: primitive.NewObjectID(), Name: "Электроника"}
+	phones := &models.Category{
+		ID:       primitive.NewObjectID(),
+		Name:     "Телефондар",
+		ParentID: electronics.ID,
+		Path:     []primitive.ObjectID{electronics.ID},
+		Schema:   []models.AttributeDef{{Key: "color", Label: "Түсі"}, {Key: "memory", Label: "Жады"}},
+	}
+	return &Shop{
+		Name:       "Qazaq Market",
+		Company:    "ЖШС «Qazaq Market»",
+		URL:        "https://market.kz",
+		Categories: []*models.Category{electronics, phones},
+		Products: []*models.Product{
+			{
+				ID:            primitive.NewObjectID(),
+				Name:          "Смартфон <Alem> & Co",
+				Description:   "Жаңа",
+				SKU:           "PH-1",
+				CategoryID:    phones.ID,
+				City:          "Алматы",
+				Price:         money.FromMinor(9990050),
+				OriginalPrice: money.Tenge(120000),
+				Sale:          &models.Sale{Active: true},
+				Stock:         4,
+				Attributes:    map[string]string{"memory": "128 ГБ", "color": "Қара", "old": "x"},
+			},
+			{
+				ID:         primitive.NewObjectID(),
+				Name:       "Қап",
+				CategoryID: electronics.ID,
+				Price:      money.Tenge(3000),
+				Stock:      -1,
+			},
+		},
+	}
+}
+
+func TestYML(t *testing.T) {
+	s := testShop()
+	generated := time.Date(2026, 10, 18, 7, 30, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err := Write(YML, &buf, s, generated); err != nil {
+		t.Fatal(err)
+	}
+	var doc ymlCatalogDoc
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+
+	if doc.Date != "2026-10-18T12:30:00+05:00" {
+		t.Errorf("date = %q, want local time", doc.Date)
+	}
+	wantCats := []ymlCategory{
+		{ID: s.Categories[0].ID.Hex(), Name: "Электроника"},
+		{ID: s.Categories[1].ID.Hex(), ParentID: s.Categories[0].ID.Hex(), Name: "Телефондар"},
+	}
+	if !reflect.DeepEqual(doc.Shop.Categories, wantCats) {
+		t.Errorf("categories = %+v, want %+v", doc.Shop.Categories, wantCats)
+	}
+	if len(doc.Shop.Offers) != 2 {
+		t.Fatalf("%d offers, want 2", len(doc.Shop.Offers))
+	}
+
+	phone := doc.Shop.Offers[0]
+	want := ymlOffer{
+		ID:          s.Products[0].ID.Hex(),
+		Available:   true,
+		URL:         "https://market.kz/product?id=" + s.Products[0].ID.Hex(),
+		Price:       "99900.50",
+		OldPrice:    "120000",
+		CurrencyID:  "KZT",
+		CategoryID:  s.Categories[1].ID.Hex(),
+		Name:        "Смартфон <Alem> & Co",
+		VendorCode:  "PH-1",
+		Description: "Жаңа",
+		Count:       4,
+		// Schema order, and only attributes the category defines.
+		Params: []ymlParam{{Name: "Түсі", Value: "Қара"}, {Name: "Жады", Value: "128 ГБ"}},
+	}
+	if !reflect.DeepEqual(phone, want) {
+		t.Errorf("offer = %+v, want %+v", phone, want)
+	}
+
+	sleeve := doc.Shop.Offers[1]
+	if sleeve.Available || sleeve.Count != 0 || sleeve.OldPrice != "" {
+		t.Errorf("sold out offer = %+v, want unavailable with count 0 and no old price", sleeve)
+	}
+}
+
+func TestGoogle(t *testing.T) {
+	s := testShop()
+
+	var buf bytes.Buffer
+	if err := Write(Google, &buf, s, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0">`,
+		`<g:title>Смартфон &lt;Alem&gt; &amp; Co</g:title>`,
+		`<g:price>120000 KZT</g:price>`,
+		`<g:sale_price>99900.50 KZT</g:sale_price>`,
+		`<g:product_type>Электроника &gt; Телефондар</g:product_type>`,
+		`<g:custom_label_0>Алматы</g:custom_label_0>`,
+		`<g:price>3000 KZT</g:price>`,
+		// Products without a description repeat their name.
+		`<g:description>Қап</g:description>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("feed does not contain %s", want)
+		}
+	}
+	if n := strings.Count(out, "<g:availability>in_stock<"); n != 1 {
+		t.Errorf("%d products in stock, want 1", n)
+	}
+	if n := strings.Count(out, "<g:sale_price>"); n != 1 {
+		t.Errorf("%d sale prices, want 1", n)
+	}
+
+	var feed struct {
+		Items []struct{} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &feed); err != nil || len(feed.Items) != 2 {
+		t.Errorf("feed parses to %d items, %v; want 2 items", len(feed.Items), err)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write("csv", &bytes.Buffer{}, testShop(), time.Now()); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Write(csv) error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestUsedCategories(t *testing.T) {
+	id := primitive.NewObjectID
+	root := &models.Category{ID: id(), Name: "Үй"}
+	kitchen := &models.Category{ID: id(), Name: "Ас үй", Path: []primitive.ObjectID{root.ID}}
+	cups := &models.Category{ID: id(), Name: "Кеселер", Path: []primitive.ObjectID{root.ID, kitchen.ID}}
+	garden := &models.Category{ID: id(), Name: "Бақ"}
+	all := []*models.Category{root, kitchen, cups, garden}
+
+	got := usedCategories(all, []*models.Product{{CategoryID: cups.ID}, {CategoryID: cups.ID}})
+	if want := []*models.Category{root, kitchen, cups}; !reflect.DeepEqual(got, want) {
+		t.Errorf("usedCategories kept %d categories, want the product's category and its ancestors", len(got))
+	}
+	if got := usedCategories(all, nil); len(got) != 0 {
+		t.Errorf("usedCategories without products = %d categories, want none", len(got))
+	}
+}
@@ -0,0 +1,85 @@
+package feeds
+
+import (
+	"encoding/xml"
+
+	"kazakh_aliexpress/internal/money"
+)
+
+// The g: prefix is written literally; encoding/xml would otherwise invent its
+// own prefixes for the namespace.
+type googleRSS struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	NS      string        `xml:"xmlns:g,attr"`
+	Channel googleChannel `xml:"channel"`
+}
+
+type googleChannel struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	Description string       `xml:"description"`
+	Items       []googleItem `xml:"item"`
+}
+
+// Products have no brands or barcodes, so identifier_exists is "no" and the
+// seller SKU goes to mpn. The city is passed as a custom label for campaign
+// filters.
+type googleItem struct {
+	ID               string `xml:"g:id"`
+	Title            string `xml:"g:title"`
+	Description      string `xml:"g:description"`
+	Link             string `xml:"g:link"`
+	Price            string `xml:"g:price"`
+	SalePrice        string `xml:"g:sale_price,omitempty"`
+	Availability     string `xml:"g:availability"`
+	Condition        string `xml:"g:condition"`
+	ProductType      string `xml:"g:product_type,omitempty"`
+	MPN              string `xml:"g:mpn,omitempty"`
+	IdentifierExists string `xml:"g:identifier_exists"`
+	City             string `xml:"g:custom_label_0,omitempty"`
+}
+
+func googlePrice(m money.Money) string {
+	return m.Decimal() + " KZT"
+}
+
+func googleFeed(s *Shop) googleRSS {
+	feed := googleRSS{
+		Version: "2.0",
+		NS:      "http://base.google.com/ns/1.0",
+		Channel: googleChannel{
+			Title:       s.Name,
+			Link:        s.URL,
+			Description: s.Company,
+		},
+	}
+
+	paths := s.categoryPaths()
+	for _, p := range s.Products {
+		item := googleItem{
+			ID:               p.ID.Hex(),
+			Title:            p.Name,
+			Description:      p.Description,
+			Link:             s.productURL(p),
+			Price:            googlePrice(p.RegularPrice()),
+			Availability:     "out_of_stock",
+			Condition:        "new",
+			ProductType:      paths[p.CategoryID],
+			MPN:              p.SKU,
+			IdentifierExists: "no",
+			City:             p.City,
+		}
+		if item.Description == "" {
+			item.Description = p.Name
+		}
+		if p.OnSale() {
+			item.SalePrice = googlePrice(p.Price)
+		}
+		if p.Stock > 0 {
+			item.Availability = "in_stock"
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
@@ -0,0 +1,102 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"time"
+
+	"kazakh_aliexpress/internal/models"
+)
+
+// ymlDate is the date format of the YML catalog attribute.
+const ymlDate = "2006-01-02T15:04:05-07:00"
+
+type ymlCatalogDoc struct {
+	XMLName xml.Name `xml:"yml_catalog"`
+	Date    string   `xml:"date,attr"`
+	Shop    ymlShop  `xml:"shop"`
+}
+
+type ymlShop struct {
+	Name       string        `xml:"name"`
+	Company    string        `xml:"company"`
+	URL        string        `xml:"url"`
+	Currencies []ymlCurrency `xml:"currencies>currency"`
+	Categories []ymlCategory `xml:"categories>category"`
+	Offers     []ymlOffer    `xml:"offers>offer"`
+}
+
+type ymlCurrency struct {
+	ID   string `xml:"id,attr"`
+	Rate string `xml:"rate,attr"`
+}
+
+type ymlCategory struct {
+	ID       string `xml:"id,attr"`
+	ParentID string `xml:"parentId,attr,omitempty"`
+	Name     string `xml:",chardata"`
+}
+
+type ymlOffer struct {
+	ID          string     `xml:"id,attr"`
+	Available   bool       `xml:"available,attr"`
+	URL         string     `xml:"url"`
+	Price       string     `xml:"price"`
+	OldPrice    string     `xml:"oldprice,omitempty"`
+	CurrencyID  string     `xml:"currencyId"`
+	CategoryID  string     `xml:"categoryId"`
+	Name        string     `xml:"name"`
+	VendorCode  string     `xml:"vendorCode,omitempty"`
+	Description string     `xml:"description,omitempty"`
+	Count       int        `xml:"count"`
+	Params      []ymlParam `xml:"param"`
+}
+
+type ymlParam struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+func ymlCatalog(s *Shop, generated time.Time) ymlCatalogDoc {
+	doc := ymlCatalogDoc{
+		Date: generated.In(models.Location).Format(ymlDate),
+		Shop: ymlShop{
+			Name:       s.Name,
+			Company:    s.Company,
+			URL:        s.URL,
+			Currencies: []ymlCurrency{{ID: "KZT", Rate: "1"}},
+		},
+	}
+
+	schemas := make(map[string][]models.AttributeDef, len(s.Categories))
+	for _, c := range s.Categories {
+		cat := ymlCategory{ID: c.ID.Hex(), Name: c.Name}
+		if !c.ParentID.IsZero() {
+			cat.ParentID = c.ParentID.Hex()
+		}
+		doc.Shop.Categories = append(doc.Shop.Categories, cat)
+		schemas[cat.ID] = c.Schema
+	}
+
+	for _, p := range s.Products {
+		offer := ymlOffer{
+			ID:          p.ID.Hex(),
+			Available:   p.Stock > 0,
+			URL:         s.productURL(p),
+			Price:       p.Price.Decimal(),
+			CurrencyID:  "KZT",
+			CategoryID:  p.CategoryID.Hex(),
+			Name:        p.Name,
+			VendorCode:  p.SKU,
+			Description: p.Description,
+			Count:       max(p.Stock, 0),
+		}
+		if p.OnSale() {
+			offer.OldPrice = p.OriginalPrice.Decimal()
+		}
+		for _, a := range models.ProductAttributeValues(schemas[offer.CategoryID], p.Attributes) {
+			offer.Params = append(offer.Params, ymlParam{Name: a.Label, Value: a.Value})
+		}
+		doc.Shop.Offers = append(doc.Shop.Offers, offer)
+	}
+	return doc
+}
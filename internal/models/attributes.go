@@ -0,0 +1,289 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AttrText   = "text"
+	AttrNumber = "number"
+	AttrBool   = "bool"
+	AttrSelect = "select"
+)
+
+var attributeKeyPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+type AttributeDef struct {
+	Key      string   `bson:"key" json:"key"`
+	Label    string   `bson:"label" json:"label"`
+	Type     string   `bson:"type" json:"type"`
+	Required bool     `bson:"required" json:"required"`
+	Options  []string `bson:"options,omitempty" json:"options,omitempty"`
+}
+
+type AttributeValue struct {
+	Label string
+	Type  string
+	Value string
+}
+
+type FacetValue struct {
+	Value    string
+	Count    int
+	Selected bool
+}
+
+type Facet struct {
+	Key    string
+	Label  string
+	Values []FacetValue
+}
+
+type AttributeError struct {
+	Label  string
+	Reason string
+}
+
+func (e *AttributeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Label, e.Reason)
+}
+
+// ParseAttributeDefs reads one attribute per line in the form
+// "key | Label | type | required | option1, option2".
+func ParseAttributeDefs(text string) ([]AttributeDef, error) {
+	var defs []AttributeDef
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, "|")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		for len(parts) < 5 {
+			parts = append(parts, "")
+		}
+
+		def := AttributeDef{
+			Key:      strings.ToLower(parts[0]),
+			Label:    parts[1],
+			Type:     strings.ToLower(parts[2]),
+			Required: strings.EqualFold(parts[3], "required"),
+		}
+		if !attributeKeyPattern.MatchString(def.Key) || seen[def.Key] {
+			return nil, fmt.Errorf("%w: attribute key %q", ErrInvalidCategory, parts[0])
+		}
+		if def.Label == "" {
+			def.Label = def.Key
+		}
+		if def.Type == "" {
+			def.Type = AttrText
+		}
+
+		switch def.Type {
+		case AttrText, AttrNumber, AttrBool:
+		case AttrSelect:
+			for _, opt := range strings.Split(parts[4], ",") {
+				if opt = strings.TrimSpace(opt); opt != "" {
+					def.Options = append(def.Options, opt)
+				}
+			}
+			if len(def.Options) == 0 {
+				return nil, fmt.Errorf("%w: attribute %q has no options", ErrInvalidCategory, def.Key)
+			}
+		default:
+			return nil, fmt.Errorf("%w: attribute type %q", ErrInvalidCategory, def.Type)
+		}
+
+		seen[def.Key] = true
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// AttributesText is the inverse of ParseAttributeDefs, used to prefill the
+// admin form.
+func (c *Category) AttributesText() string {
+	var lines []string
+	for _, d := range c.Attributes {
+		required := ""
+		if d.Required {
+			required = "required"
+		}
+		lines = append(lines, strings.Join([]string{d.Key, d.Label, d.Type, required, strings.Join(d.Options, ", ")}, " | "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inheritSchema sets the effective schema of every category: its ancestors'
+// attributes followed by its own. cats must be in tree order.
+func inheritSchema(cats []*Category) {
+	byID := make(map[primitive.ObjectID]*Category, len(cats))
+	for _, c := range cats {
+		byID[c.ID] = c
+		c.Schema = nil
+		if parent, ok := byID[c.ParentID]; ok {
+			c.Schema = append(c.Schema, parent.Schema...)
+		}
+		c.Schema = append(c.Schema, c.Attributes...)
+	}
+}
+
+func (m *MongoDB) GetCategorySchema(id primitive.ObjectID) ([]AttributeDef, error) {
+	crumbs, err := m.GetCategoryBreadcrumbs(id)
+	if err != nil {
+		return nil, err
+	}
+	var schema []AttributeDef
+	for _, c := range crumbs {
+		schema = append(schema, c.Attributes...)
+	}
+	return schema, nil
+}
+
+// ValidateAttributes checks submitted values against a category schema and
+// returns the normalized values to store. Unknown keys are dropped.
+func ValidateAttributes(schema []AttributeDef, values map[string]string) (map[string]string, error) {
+	clean := make(map[string]string)
+
+	for _, def := range schema {
+		v := strings.TrimSpace(values[def.Key])
+
+		if v == "" {
+			if def.Required && def.Type != AttrBool {
+				return nil, &AttributeError{Label: def.Label, Reason: "required"}
+			}
+			continue
+		}
+
+		switch def.Type {
+		case AttrNumber:
+			n, err := strconv.ParseFloat(strings.ReplaceAll(v, ",", "."), 64)
+			if err != nil {
+				return nil, &AttributeError{Label: def.Label, Reason: "must be a number"}
+			}
+			v = strconv.FormatFloat(n, 'f', -1, 64)
+		case AttrBool:
+			if v != "true" {
+				continue
+			}
+		case AttrSelect:
+			found := false
+			for _, opt := range def.Options {
+				if opt == v {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, &AttributeError{Label: def.Label, Reason: "unknown option"}
+			}
+		}
+
+		clean[def.Key] = v
+	}
+	return clean, nil
+}
+
+func ProductAttributeValues(schema []AttributeDef, values map[string]string) []AttributeValue {
+	var out []AttributeValue
+	for _, def := range schema {
+		v, ok := values[def.Key]
+		if !ok {
+			continue
+		}
+		out = append(out, AttributeValue{Label: def.Label, Type: def.Type, Value: v})
+	}
+	return out
+}
+
+// GetAttributeFacets counts the attribute values of the products the catalog
+// would list for every attribute in the schema. The count for a value
+// applies every selected filter except the one on its own attribute, so it
+// tells how many products picking that value would show.
+func (m *MongoDB) GetAttributeFacets(search, city string, categoryIDs []primitive.ObjectID, schema []AttributeDef, selected map[string]string) ([]Facet, error) {
+	facetPipelines := bson.M{}
+	for _, def := range schema {
+		if !attributeKeyPattern.MatchString(def.Key) {
+			continue
+		}
+		facetPipelines[def.Key] = bson.A{
+			bson.M{"$match": facetFilter(def.Key, selected)},
+			bson.M{"$group": bson.M{"_id": "$attributes." + def.Key, "count": bson.M{"$sum": 1}}},
+		}
+	}
+	if len(facetPipelines) == 0 {
+		return nil, nil
+	}
+
+	pipeline := []bson.M{
+		{"$match": productFilter(search, categoryIDs, city, nil)},
+		{"$facet": facetPipelines},
+	}
+	cur, err := m.Products.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var counts map[string][]struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if cur.Next(context.TODO()) {
+		if err := cur.Decode(&counts); err != nil {
+			return nil, err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]FacetValue)
+	for key, rows := range counts {
+		for _, row := range rows {
+			values[key] = append(values[key], FacetValue{
+				Value:    row.Value,
+				Count:    row.Count,
+				Selected: selected[key] == row.Value,
+			})
+		}
+	}
+
+	var facets []Facet
+	for _, def := range schema {
+		vals := values[def.Key]
+		if len(vals) == 0 {
+			continue
+		}
+		sort.Slice(vals, func(i, j int) bool { return vals[i].Value < vals[j].Value })
+		facets = append(facets, Facet{Key: def.Key, Label: def.Label, Values: vals})
+	}
+	return facets, nil
+}
+
+// facetFilter matches the products having the attribute key among those
+// passing the other selected attributes.
+func facetFilter(key string, selected map[string]string) bson.M {
+	others := make(map[string]string, len(selected))
+	for k, v := range selected {
+		if k != key {
+			others[k] = v
+		}
+	}
+	filter := productFilter("", nil, "", others)
+	filter["attributes."+key] = bson.M{"$exists": true}
+	return filter
+}
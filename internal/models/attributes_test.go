@@ -0,0 +1,45 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestProductFilter(t *testing.T) {
+	got := productFilter("phone", nil, "Алматы", map[string]string{"color": "red", "Bad-Key": "x", "size": ""})
+	want := bson.M{
+		"name":             bson.M{"$regex": "phone", "$options": "i"},
+		"city":             "Алматы",
+		"attributes.color": "red",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("productFilter = %v, want %v", got, want)
+	}
+}
+
+// A facet counts products under every selected attribute but its own, so
+// picking a color does not hide the other colors.
+func TestFacetFilter(t *testing.T) {
+	selected := map[string]string{"color": "red", "size": "M"}
+
+	got := facetFilter("color", selected)
+	want := bson.M{
+		"attributes.size":  "M",
+		"attributes.color": bson.M{"$exists": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("facetFilter(color) = %v, want %v", got, want)
+	}
+
+	got = facetFilter("brand", selected)
+	want = bson.M{
+		"attributes.color": "red",
+		"attributes.size":  "M",
+		"attributes.brand": bson.M{"$exists": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("facetFilter(brand) = %v, want %v", got, want)
+	}
+}
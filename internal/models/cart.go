@@ -2,27 +2,292 @@ package models
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var ErrOutOfStock = errors.New("product is out of stock")
+
 type CartItem struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"`
+	GuestID   string             `bson:"guest_id,omitempty" json:"-"`
 	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
 	Quantity  int                `bson:"quantity" json:"quantity"`
 	Name      string             `bson:"name" json:"name"`
-	Price     float64            `bson:"price" json:"price"`
-	Total     float64            `bson:"total" json:"total"`
+	Price     money.Money        `bson:"price" json:"price"`
+	Total     money.Money        `bson:"-" json:"total"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	CurrentPrice money.Money `bson:"-" json:"current_price"`
+	Stock        int         `bson:"-" json:"stock"`
+	Issue        string      `bson:"-" json:"issue,omitempty"`
+}
+
+const (
+	CartIssuePriceChanged = "price_changed"
+	CartIssueLowStock     = "low_stock"
+	CartIssueUnavailable  = "unavailable"
+)
+
+// CartOwner identifies whose cart is being used: a logged-in user or an
+// anonymous visitor identified by an id kept in their session.
+type CartOwner struct {
+	UserID  primitive.ObjectID
+	GuestID string
+}
+
+func (o CartOwner) filter() bson.M {
+	if o.UserID.IsZero() {
+		return bson.M{"guest_id": o.GuestID}
+	}
+	return bson.M{"user_id": o.UserID}
+}
+
+func (o CartOwner) itemFilter(productID primitive.ObjectID) bson.M {
+	f := o.filter()
+	f["product_id"] = productID
+	return f
 }
 
 func (m *MongoDB) GetUserCart(userID primitive.ObjectID) ([]*CartItem, error) {
+	return m.GetCart(CartOwner{UserID: userID})
+}
+
+func (m *MongoDB) GetCart(owner CartOwner) ([]*CartItem, error) {
 	var items []*CartItem
-	cursor, err := m.Users.Database().Collection("cart").Find(context.TODO(), bson.M{"user_id": userID})
+	cursor, err := m.Carts.Find(context.TODO(), owner.filter())
 	if err != nil {
 		return nil, err
 	}
 	err = cursor.All(context.TODO(), &items)
 	return items, err
 }
+
+func (m *MongoDB) getCartItem(owner CartOwner, productID primitive.ObjectID) (*CartItem, error) {
+	var item CartItem
+	err := m.Carts.FindOne(context.TODO(), owner.itemFilter(productID)).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// touch marks the whole cart as active so the expiry job only removes carts
+// nobody has used for a while.
+func (m *MongoDB) touch(owner CartOwner) error {
+	_, err := m.Carts.UpdateMany(context.TODO(), owner.filter(), bson.M{"$set": bson.M{"updated_at": time.Now()}})
+	return err
+}
+
+// AddToCart adds qty units of the product to the cart. The line never holds
+// more units than the product has in stock.
+func (m *MongoDB) AddToCart(owner CartOwner, product *Product, qty int) error {
+	if product.Stock <= 0 {
+		return ErrOutOfStock
+	}
+
+	existing, err := m.getCartItem(owner, product.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		qty += existing.Quantity
+	}
+	if qty > product.Stock {
+		qty = product.Stock
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":     product.Name,
+			"price":    product.Price,
+			"quantity": qty,
+		},
+	}
+	opts := options.Update().SetUpsert(true)
+
+	_, err = m.Carts.UpdateOne(context.TODO(), owner.itemFilter(product.ID), update, opts)
+	if err != nil {
+		return err
+	}
+	return m.touch(owner)
+}
+
+// SetCartQuantity changes the quantity of a cart line, capped by the stock.
+// A quantity of zero or less removes the line.
+func (m *MongoDB) SetCartQuantity(owner CartOwner, product *Product, qty int) error {
+	if qty <= 0 {
+		return m.RemoveFromCart(owner, product.ID)
+	}
+	if qty > product.Stock {
+		qty = product.Stock
+	}
+	if qty <= 0 {
+		return ErrOutOfStock
+	}
+
+	_, err := m.Carts.UpdateOne(context.TODO(), owner.itemFilter(product.ID), bson.M{"$set": bson.M{"quantity": qty}})
+	if err != nil {
+		return err
+	}
+	return m.touch(owner)
+}
+
+func (m *MongoDB) RemoveFromCart(owner CartOwner, productID primitive.ObjectID) error {
+	_, err := m.Carts.DeleteOne(context.TODO(), owner.itemFilter(productID))
+	if err != nil {
+		return err
+	}
+	return m.touch(owner)
+}
+
+func (m *MongoDB) ClearCart(owner CartOwner) error {
+	_, err := m.Carts.DeleteMany(context.TODO(), owner.filter())
+	return err
+}
+
+// ExpireCarts deletes carts that have not been touched for longer than maxAge.
+func (m *MongoDB) ExpireCarts(maxAge time.Duration) (int64, error) {
+	res, err := m.Carts.DeleteMany(context.TODO(), expiredCartFilter(time.Now(), maxAge))
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// expiredCartFilter matches the cart lines last touched more than maxAge
+// before now. Lines without updated_at are dated by migration 4 instead.
+func expiredCartFilter(now time.Time, maxAge time.Duration) bson.M {
+	return bson.M{"updated_at": bson.M{"$lt": now.Add(-maxAge)}}
+}
+
+// backfillCartUpdatedAt dates cart lines from before updated_at was tracked
+// to now, so they get the full expiry period instead of being deleted on the
+// first run of the expiry job.
+func (m *MongoDB) backfillCartUpdatedAt() error {
+	_, err := m.Carts.UpdateMany(context.TODO(), bson.M{"updated_at": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"updated_at": time.Now()}})
+	return err
+}
+
+// MergeGuestCart moves a guest's cart lines into the user's cart, summing the
+// quantities of products that are in both.
+func (m *MongoDB) MergeGuestCart(guestID string, userID primitive.ObjectID) error {
+	guest := CartOwner{GuestID: guestID}
+	user := CartOwner{UserID: userID}
+
+	items, err := m.GetCart(guest)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		update := bson.M{
+			"$set": bson.M{"name": item.Name, "price": item.Price},
+			"$inc": bson.M{"quantity": item.Quantity},
+		}
+		_, err := m.Carts.UpdateOne(context.TODO(), user.itemFilter(item.ProductID), update, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := m.ClearCart(guest); err != nil {
+		return err
+	}
+	return m.touch(user)
+}
+
+// ValidateCart re-prices cart lines against the current products and flags
+// lines whose price changed since they were added, whose quantity exceeds the
+// stock, or whose product no longer exists. It reports whether any line needs
+// the customer's attention.
+func (m *MongoDB) ValidateCart(items []*CartItem) (bool, error) {
+	if len(items) == 0 {
+		return false, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+
+	byID, err := m.productsByID(ids)
+	if err != nil {
+		return false, err
+	}
+	return checkCartItems(items, byID), nil
+}
+
+// checkCartItems sets the issue, current price and stock of each line from
+// the products, keyed by id, and reports whether any line has an issue.
+func checkCartItems(items []*CartItem, products map[primitive.ObjectID]*Product) bool {
+	hasIssues := false
+	for _, item := range items {
+		p, ok := products[item.ProductID]
+		switch {
+		case !ok:
+			item.Issue = CartIssueUnavailable
+		case p.Stock < item.Quantity:
+			item.Issue = CartIssueLowStock
+		case p.Price.Amount != item.Price.Amount:
+			item.Issue = CartIssuePriceChanged
+		default:
+			item.Issue = ""
+		}
+
+		if ok {
+			item.CurrentPrice = p.Price
+			item.Stock = p.Stock
+			item.Name = p.Name
+		}
+		if item.Issue != "" {
+			hasIssues = true
+		}
+	}
+	return hasIssues
+}
+
+// AcknowledgeCartChanges accepts the current state of the catalog: prices are
+// updated, quantities are cut down to the available stock and lines for
+// deleted or sold-out products are removed.
+func (m *MongoDB) AcknowledgeCartChanges(owner CartOwner) error {
+	items, err := m.GetCart(owner)
+	if err != nil {
+		return err
+	}
+	if _, err := m.ValidateCart(items); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		filter := owner.itemFilter(item.ProductID)
+
+		if item.Issue == CartIssueUnavailable || (item.Issue == CartIssueLowStock && item.Stock <= 0) {
+			if _, err := m.Carts.DeleteOne(context.TODO(), filter); err != nil {
+				return err
+			}
+			continue
+		}
+
+		qty := item.Quantity
+		if item.Stock < qty {
+			qty = item.Stock
+		}
+		update := bson.M{"$set": bson.M{"name": item.Name, "price": item.CurrentPrice, "quantity": qty}}
+		if _, err := m.Carts.UpdateOne(context.TODO(), filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
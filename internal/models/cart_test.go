@@ -0,0 +1,99 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCartOwnerFilter(t *testing.T) {
+	user := primitive.NewObjectID()
+	product := primitive.NewObjectID()
+
+	tests := []struct {
+		name  string
+		owner CartOwner
+		want  bson.M
+	}{
+		{"user", CartOwner{UserID: user}, bson.M{"user_id": user, "product_id": product}},
+		{"guest", CartOwner{GuestID: "g1"}, bson.M{"guest_id": "g1", "product_id": product}},
+		// A guest who logged in keeps the guest id, but the user's cart wins.
+		{"user with guest id", CartOwner{UserID: user, GuestID: "g1"}, bson.M{"user_id": user, "product_id": product}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.owner.itemFilter(product); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("itemFilter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCartItems(t *testing.T) {
+	product := func(price int64, stock int) *Product {
+		return &Product{ID: primitive.NewObjectID(), Name: "Кесе", Price: money.Tenge(price), Stock: stock}
+	}
+	same, cheaper, scarce, soldOut := product(1000, 5), product(900, 5), product(1000, 2), product(1000, 0)
+	products := map[primitive.ObjectID]*Product{}
+	for _, p := range []*Product{same, cheaper, scarce, soldOut} {
+		products[p.ID] = p
+	}
+
+	line := func(p *Product, qty int) *CartItem {
+		id := primitive.NewObjectID()
+		if p != nil {
+			id = p.ID
+		}
+		return &CartItem{ProductID: id, Name: "ескі атау", Quantity: qty, Price: money.Tenge(1000), Issue: CartIssuePriceChanged}
+	}
+	items := []*CartItem{
+		line(same, 5),
+		line(cheaper, 1),
+		line(scarce, 3),
+		line(soldOut, 1),
+		line(nil, 1),
+	}
+
+	if !checkCartItems(items, products) {
+		t.Error("checkCartItems reported no issues")
+	}
+
+	want := []struct {
+		issue string
+		price int64
+		stock int
+	}{
+		{"", 100000, 5},
+		{CartIssuePriceChanged, 90000, 5},
+		{CartIssueLowStock, 100000, 2},
+		{CartIssueLowStock, 100000, 0},
+		{CartIssueUnavailable, 0, 0},
+	}
+	for i, w := range want {
+		item := items[i]
+		if item.Issue != w.issue || item.CurrentPrice.Amount != w.price || item.Stock != w.stock {
+			t.Errorf("line %d = issue %q, price %d, stock %d; want %q, %d, %d",
+				i, item.Issue, item.CurrentPrice.Amount, item.Stock, w.issue, w.price, w.stock)
+		}
+	}
+	if items[0].Name != "Кесе" {
+		t.Errorf("line name = %q, want the current product name", items[0].Name)
+	}
+
+	if checkCartItems(items[:1], products) {
+		t.Error("checkCartItems reported issues for an unchanged line")
+	}
+}
+
+func TestExpiredCartFilter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	want := bson.M{"updated_at": bson.M{"$lt": time.Date(2026, 10, 11, 12, 0, 0, 0, time.UTC)}}
+	if got := expiredCartFilter(now, 7*24*time.Hour); !reflect.DeepEqual(got, want) {
+		t.Errorf("expiredCartFilter = %v, want %v", got, want)
+	}
+}
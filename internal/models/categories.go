@@ -0,0 +1,387 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidCategory = errors.New("invalid category")
+	ErrCategoryInUse   = errors.New("category still has products")
+)
+
+var translit = map[rune]string{
+	'а': "a", 'ә': "a", 'б': "b", 'в': "v", 'г': "g", 'ғ': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'қ': "q", 'л': "l", 'м': "m", 'н': "n",
+	'ң': "n", 'о': "o", 'ө': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ұ': "u",
+	'ү': "u", 'ф': "f", 'х': "kh", 'һ': "h", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'і': "i", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// Slugify turns a category name into a lowercase latin URL segment.
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		latin, cyrillic := translit[r]
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			dash = false
+		case cyrillic:
+			// The hard and soft signs have no latin letter and are dropped.
+			if latin != "" {
+				b.WriteString(latin)
+				dash = false
+			}
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+func (c *Category) Indent() string {
+	return strings.Repeat("— ", len(c.Path))
+}
+
+// URL is the SEO-friendly catalog address of the category. Categories created
+// before slugs existed fall back to the id filter.
+func (c *Category) URL() string {
+	if c.Slug == "" {
+		return "/catalog?category=" + c.ID.Hex()
+	}
+	return "/c/" + c.Slug
+}
+
+func (m *MongoDB) GetCategory(id primitive.ObjectID) (*Category, error) {
+	var c Category
+	err := m.Categories.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&c)
+	return &c, err
+}
+
+func (m *MongoDB) GetCategoryBySlug(slug string) (*Category, error) {
+	var c Category
+	err := m.Categories.FindOne(context.TODO(), bson.M{"slug": slug}).Decode(&c)
+	return &c, err
+}
+
+// GetAllCategories returns categories in tree order: every parent is followed
+// by its children, siblings ordered by sort order and name.
+func (m *MongoDB) GetAllCategories() ([]*Category, error) {
+	var cats []*Category
+	cur, err := m.Categories.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &cats); err != nil {
+		return nil, err
+	}
+	cats = sortCategoryTree(cats)
+	inheritSchema(cats)
+	return cats, nil
+}
+
+// sortCategoryTree orders categories parent first. A category whose parent
+// no longer exists is listed as a root, and any category left unreached (a
+// parent cycle) is appended at the end, so none disappears from the admin.
+func sortCategoryTree(cats []*Category) []*Category {
+	known := make(map[primitive.ObjectID]bool, len(cats))
+	for _, c := range cats {
+		known[c.ID] = true
+	}
+	children := make(map[primitive.ObjectID][]*Category)
+	for _, c := range cats {
+		parent := c.ParentID
+		if !known[parent] {
+			parent = primitive.NilObjectID
+		}
+		children[parent] = append(children[parent], c)
+	}
+	for _, list := range children {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].SortOrder != list[j].SortOrder {
+				return list[i].SortOrder < list[j].SortOrder
+			}
+			return list[i].Name < list[j].Name
+		})
+	}
+
+	sorted := make([]*Category, 0, len(cats))
+	seen := make(map[primitive.ObjectID]bool, len(cats))
+	var walk func(parent primitive.ObjectID)
+	walk = func(parent primitive.ObjectID) {
+		for _, c := range children[parent] {
+			if seen[c.ID] {
+				continue
+			}
+			seen[c.ID] = true
+			sorted = append(sorted, c)
+			walk(c.ID)
+		}
+	}
+	walk(primitive.NilObjectID)
+	for _, c := range cats {
+		if !seen[c.ID] {
+			seen[c.ID] = true
+			sorted = append(sorted, c)
+			walk(c.ID)
+		}
+	}
+	return sorted
+}
+
+// GetCategoryBreadcrumbs returns the ancestors of a category followed by the
+// category itself.
+func (m *MongoDB) GetCategoryBreadcrumbs(id primitive.ObjectID) ([]*Category, error) {
+	cat, err := m.GetCategory(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cat.Path) == 0 {
+		return []*Category{cat}, nil
+	}
+
+	var ancestors []*Category
+	cur, err := m.Categories.Find(context.TODO(), bson.M{"_id": bson.M{"$in": cat.Path}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &ancestors); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ancestors, func(i, j int) bool { return len(ancestors[i].Path) < len(ancestors[j].Path) })
+	return append(ancestors, cat), nil
+}
+
+// GetCategoryWithDescendants returns the ids of a category and all categories
+// nested below it.
+func (m *MongoDB) GetCategoryWithDescendants(id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	values, err := m.Categories.Distinct(context.TODO(), "_id", bson.M{"path": id})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []primitive.ObjectID{id}
+	for _, v := range values {
+		if oid, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, oid)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MongoDB) categoryPath(parentID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if parentID.IsZero() {
+		return []primitive.ObjectID{}, nil
+	}
+	parent, err := m.GetCategory(parentID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidCategory
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]primitive.ObjectID{}, parent.Path...), parent.ID), nil
+}
+
+func (m *MongoDB) uniqueSlug(slug string, self primitive.ObjectID) (string, error) {
+	base := slug
+	for i := 2; ; i++ {
+		n, err := m.Categories.CountDocuments(context.TODO(), bson.M{"slug": slug, "_id": bson.M{"$ne": self}})
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return slug, nil
+		}
+		slug = base + "-" + strconv.Itoa(i)
+	}
+}
+
+func (m *MongoDB) prepareCategory(c *Category) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return ErrInvalidCategory
+	}
+
+	c.Slug = Slugify(c.Slug)
+	if c.Slug == "" {
+		c.Slug = Slugify(c.Name)
+	}
+	if c.Slug == "" {
+		c.Slug = "category"
+	}
+
+	var err error
+	c.Slug, err = m.uniqueSlug(c.Slug, c.ID)
+	if err != nil {
+		return err
+	}
+
+	c.Path, err = m.categoryPath(c.ParentID)
+	return err
+}
+
+// backfillCategories gives categories created before the category tree an
+// empty path and a slug. They have no parent, like every root category.
+func (m *MongoDB) backfillCategories() error {
+	_, err := m.Categories.UpdateMany(context.TODO(),
+		bson.M{"path": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"path": []primitive.ObjectID{}}})
+	if err != nil {
+		return err
+	}
+
+	var cats []*Category
+	cur, err := m.Categories.Find(context.TODO(), bson.M{"$or": bson.A{
+		bson.M{"slug": bson.M{"$exists": false}},
+		bson.M{"slug": ""},
+	}})
+	if err != nil {
+		return err
+	}
+	if err := cur.All(context.TODO(), &cats); err != nil {
+		return err
+	}
+	for _, c := range cats {
+		slug := Slugify(c.Name)
+		if slug == "" {
+			slug = "category"
+		}
+		if slug, err = m.uniqueSlug(slug, c.ID); err != nil {
+			return err
+		}
+		if _, err := m.Categories.UpdateOne(context.TODO(), bson.M{"_id": c.ID}, bson.M{"$set": bson.M{"slug": slug}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MongoDB) AddCategory(c Category) error {
+	c.ID = primitive.NewObjectID()
+	if err := m.prepareCategory(&c); err != nil {
+		return err
+	}
+	_, err := m.Categories.InsertOne(context.TODO(), c)
+	return err
+}
+
+// UpdateCategory renames or moves a category. Moving rewrites the stored path
+// of every descendant.
+func (m *MongoDB) UpdateCategory(c Category) error {
+	old, err := m.GetCategory(c.ID)
+	if err != nil {
+		return err
+	}
+	if err := m.prepareCategory(&c); err != nil {
+		return err
+	}
+	for _, ancestor := range c.Path {
+		if ancestor == c.ID {
+			return ErrInvalidCategory
+		}
+	}
+
+	set := bson.M{"name": c.Name, "slug": c.Slug, "path": c.Path, "sort_order": c.SortOrder, "attributes": c.Attributes}
+	update := bson.M{"$set": set}
+	if c.ParentID.IsZero() {
+		update["$unset"] = bson.M{"parent_id": ""}
+	} else {
+		set["parent_id"] = c.ParentID
+	}
+	if _, err := m.Categories.UpdateOne(context.TODO(), bson.M{"_id": c.ID}, update); err != nil {
+		return err
+	}
+
+	if old.ParentID == c.ParentID {
+		return nil
+	}
+	return m.rebaseDescendants(c.ID, append(c.Path, c.ID))
+}
+
+func (m *MongoDB) rebaseDescendants(id primitive.ObjectID, prefix []primitive.ObjectID) error {
+	var descendants []*Category
+	cur, err := m.Categories.Find(context.TODO(), bson.M{"path": id})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &descendants); err != nil {
+		return err
+	}
+
+	for _, d := range descendants {
+		var tail []primitive.ObjectID
+		for i, p := range d.Path {
+			if p == id {
+				tail = d.Path[i+1:]
+				break
+			}
+		}
+		path := append(append([]primitive.ObjectID{}, prefix...), tail...)
+		if _, err := m.Categories.UpdateOne(context.TODO(), bson.M{"_id": d.ID}, bson.M{"$set": bson.M{"path": path}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCategory removes a category, moving its products to reassignTo and its
+// subcategories up to its parent. Deleting a category that still has products
+// without a reassignment target fails with ErrCategoryInUse.
+func (m *MongoDB) DeleteCategory(id, reassignTo primitive.ObjectID) error {
+	cat, err := m.GetCategory(id)
+	if err != nil {
+		return err
+	}
+
+	if reassignTo.IsZero() {
+		n, err := m.Products.CountDocuments(context.TODO(), bson.M{"category_id": id})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrCategoryInUse
+		}
+	} else {
+		if reassignTo == id {
+			return ErrInvalidCategory
+		}
+		if _, err := m.GetCategory(reassignTo); err != nil {
+			return ErrInvalidCategory
+		}
+		_, err := m.Products.UpdateMany(context.TODO(), bson.M{"category_id": id}, bson.M{"$set": bson.M{"category_id": reassignTo}})
+		if err != nil {
+			return err
+		}
+	}
+
+	childUpdate := bson.M{"$unset": bson.M{"parent_id": ""}}
+	if !cat.ParentID.IsZero() {
+		childUpdate = bson.M{"$set": bson.M{"parent_id": cat.ParentID}}
+	}
+	if _, err := m.Categories.UpdateMany(context.TODO(), bson.M{"parent_id": id}, childUpdate); err != nil {
+		return err
+	}
+	if _, err := m.Categories.UpdateMany(context.TODO(), bson.M{"path": id}, bson.M{"$pull": bson.M{"path": id}}); err != nil {
+		return err
+	}
+
+	_, err = m.Categories.DeleteOne(context.TODO(), bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Электроника", "elektronika"},
+		{"Ұялы телефондар", "uyaly-telefondar"},
+		{"Қазақша әзірлемелер", "qazaqsha-azirlemeler"},
+		{"Шұжық & ірімшік", "shuzhyq-irimshik"},
+		{"TV, аудио  және видео", "tv-audio-zhane-video"},
+		{"  --Жаңа!--  ", "zhana"},
+		{"iPhone 15 Pro", "iphone-15-pro"},
+		{"Объявления", "obyavleniya"},
+		{"Альбом", "albom"},
+		{"", ""},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.in); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSortCategoryTree(t *testing.T) {
+	id := func() primitive.ObjectID { return primitive.NewObjectID() }
+	var (
+		home    = &Category{ID: id(), Name: "Үй", SortOrder: 2}
+		tech    = &Category{ID: id(), Name: "Техника", SortOrder: 1}
+		phones  = &Category{ID: id(), Name: "Телефондар"}
+		laptops = &Category{ID: id(), Name: "Ноутбуктер"}
+		cases   = &Category{ID: id(), Name: "Қаптар"}
+		orphan  = &Category{ID: id(), Name: "Ескі", ParentID: id()}
+		loopA   = &Category{ID: id(), Name: "A"}
+		loopB   = &Category{ID: id(), Name: "B"}
+	)
+	phones.ParentID = tech.ID
+	laptops.ParentID = tech.ID
+	cases.ParentID = phones.ID
+	loopA.ParentID = loopB.ID
+	loopB.ParentID = loopA.ID
+
+	got := sortCategoryTree([]*Category{cases, loopA, home, orphan, phones, loopB, laptops, tech})
+
+	var names []string
+	for _, c := range got {
+		names = append(names, c.Name)
+	}
+	want := []string{"Ескі", "Техника", "Ноутбуктер", "Телефондар", "Қаптар", "Үй", "A", "B"}
+	if !slices.Equal(names, want) {
+		t.Errorf("sortCategoryTree = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OrderProblem is an order whose lines refer to products that no longer
+// exist.
+type OrderProblem struct {
+	OrderID primitive.ObjectID   `bson:"_id"`
+	Missing []primitive.ObjectID `bson:"missing"`
+}
+
+// GetOrphanCartItems returns cart items of deleted products or deleted users.
+// Guest items have no user and are left to cart expiry.
+func (m *MongoDB) GetOrphanCartItems() ([]*CartItem, error) {
+	pipeline := []bson.M{
+		{"$lookup": bson.M{"from": m.Products.Name(), "localField": "product_id", "foreignField": "_id", "as": "product"}},
+		{"$lookup": bson.M{"from": m.Users.Name(), "localField": "user_id", "foreignField": "_id", "as": "user"}},
+		{"$match": bson.M{"$or": []bson.M{
+			{"product": bson.M{"$size": 0}},
+			{"user_id": bson.M{"$exists": true}, "user": bson.M{"$size": 0}},
+		}}},
+		{"$project": bson.M{"product": 0, "user": 0}},
+	}
+	cur, err := m.Carts.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var items []*CartItem
+	err = cur.All(context.TODO(), &items)
+	return items, err
+}
+
+func (m *MongoDB) DeleteCartItems(ids []primitive.ObjectID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res, err := m.Carts.DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// GetOrdersWithMissingProducts lists orders with lines of deleted products.
+// The orders themselves stay valid, since lines keep the name and price.
+func (m *MongoDB) GetOrdersWithMissingProducts() ([]OrderProblem, error) {
+	pipeline := []bson.M{
+		{"$lookup": bson.M{"from": m.Products.Name(), "localField": "items.product_id", "foreignField": "_id", "as": "found"}},
+		{"$project": bson.M{"missing": bson.M{"$setDifference": bson.A{"$items.product_id", "$found._id"}}}},
+		{"$match": bson.M{"missing.0": bson.M{"$exists": true}}},
+	}
+	cur, err := m.Orders.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var problems []OrderProblem
+	err = cur.All(context.TODO(), &problems)
+	return problems, err
+}
+
+func (m *MongoDB) GetNegativeStockProducts() ([]*Product, error) {
+	cur, err := m.Products.Find(context.TODO(), bson.M{"stock": bson.M{"$lt": 0}})
+	if err != nil {
+		return nil, err
+	}
+	var products []*Product
+	err = cur.All(context.TODO(), &products)
+	return products, err
+}
+
+// ResetNegativeStock sets negative stock counts to zero.
+func (m *MongoDB) ResetNegativeStock() (int, error) {
+	res, err := m.Products.UpdateMany(context.TODO(), bson.M{"stock": bson.M{"$lt": 0}}, bson.M{"$set": bson.M{"stock": 0}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.ModifiedCount), nil
+}
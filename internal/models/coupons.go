@@ -0,0 +1,295 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	CouponPercent = "percent"
+	CouponFixed   = "fixed"
+)
+
+var (
+	ErrInvalidCoupon       = errors.New("invalid coupon")
+	ErrCouponNotFound      = errors.New("coupon not found")
+	ErrCouponNotActive     = errors.New("coupon is not active")
+	ErrCouponMinOrder      = errors.New("order total is below the coupon minimum")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to any item")
+	ErrCouponUsedUp        = errors.New("coupon usage limit reached")
+)
+
+// Coupon is a promo code. Percent coupons take Value percent off, fixed ones
+// take Amount off. A coupon scoped to a category or a seller
+// only discounts the matching lines, and the minimum order value is checked
+// against those lines too. Zero limits and zero dates mean "no limit".
+type Coupon struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code           string             `bson:"code" json:"code"`
+	Type           string             `bson:"type" json:"type"`
+	Value          float64            `bson:"value,omitempty" json:"value,omitempty"`
+	Amount         money.Money        `bson:"amount,omitempty" json:"amount,omitempty"`
+	MinOrder       money.Money        `bson:"min_order" json:"min_order"`
+	CategoryID     primitive.ObjectID `bson:"category_id,omitempty" json:"category_id,omitempty"`
+	SellerID       primitive.ObjectID `bson:"seller_id,omitempty" json:"seller_id,omitempty"`
+	MaxUses        int                `bson:"max_uses" json:"max_uses"`
+	MaxUsesPerUser int                `bson:"max_uses_per_user" json:"max_uses_per_user"`
+	Used           int                `bson:"used" json:"used"`
+	StartsAt       time.Time          `bson:"starts_at,omitempty" json:"starts_at,omitempty"`
+	EndsAt         time.Time          `bson:"ends_at,omitempty" json:"ends_at,omitempty"`
+	Active         bool               `bson:"active" json:"active"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OrderDiscount records the coupon applied to an order. The amount is also
+// spread over the order lines in OrderItem.Discount.
+type OrderDiscount struct {
+	CouponID primitive.ObjectID `bson:"coupon_id" json:"coupon_id"`
+	Code     string             `bson:"code" json:"code"`
+	Amount   money.Money        `bson:"amount" json:"amount"`
+}
+
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (c *Coupon) Validate() error {
+	c.Code = NormalizeCouponCode(c.Code)
+	switch {
+	case c.Code == "":
+		return ErrInvalidCoupon
+	case c.Type != CouponPercent && c.Type != CouponFixed:
+		return ErrInvalidCoupon
+	case c.Type == CouponPercent && (c.Value <= 0 || c.Value > 100):
+		return ErrInvalidCoupon
+	case c.Type == CouponFixed && !c.Amount.IsPositive():
+		return ErrInvalidCoupon
+	case c.MinOrder.Amount < 0 || c.MaxUses < 0 || c.MaxUsesPerUser < 0:
+		return ErrInvalidCoupon
+	case !c.StartsAt.IsZero() && !c.EndsAt.IsZero() && !c.EndsAt.After(c.StartsAt):
+		return ErrInvalidCoupon
+	}
+	return nil
+}
+
+func (c *Coupon) activeAt(t time.Time) bool {
+	if !c.Active {
+		return false
+	}
+	if !c.StartsAt.IsZero() && t.Before(c.StartsAt) {
+		return false
+	}
+	if !c.EndsAt.IsZero() && !t.Before(c.EndsAt) {
+		return false
+	}
+	return true
+}
+
+func (m *MongoDB) AddCoupon(c Coupon) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	n, err := m.Coupons.CountDocuments(context.TODO(), bson.M{"code": c.Code})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrInvalidCoupon
+	}
+
+	c.ID = primitive.NewObjectID()
+	c.Used = 0
+	c.Active = true
+	c.CreatedAt = time.Now()
+	_, err = m.Coupons.InsertOne(context.TODO(), c)
+	return err
+}
+
+func (m *MongoDB) GetCoupon(id primitive.ObjectID) (*Coupon, error) {
+	var c Coupon
+	err := m.Coupons.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&c)
+	return &c, err
+}
+
+func (m *MongoDB) GetCouponByCode(code string) (*Coupon, error) {
+	var c Coupon
+	err := m.Coupons.FindOne(context.TODO(), bson.M{"code": NormalizeCouponCode(code)}).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCouponNotFound
+	}
+	return &c, err
+}
+
+// GetCoupons lists coupons, newest first. A non-zero sellerID limits the list
+// to that seller's coupons.
+func (m *MongoDB) GetCoupons(sellerID primitive.ObjectID) ([]*Coupon, error) {
+	filter := bson.M{}
+	if !sellerID.IsZero() {
+		filter["seller_id"] = sellerID
+	}
+
+	var coupons []*Coupon
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cur, err := m.Coupons.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &coupons)
+	return coupons, err
+}
+
+func (m *MongoDB) SetCouponActive(id primitive.ObjectID, active bool) error {
+	_, err := m.Coupons.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"active": active}})
+	return err
+}
+
+// ApplyCoupon checks that the coupon can be used by the user for these order
+// lines and spreads the discount over the eligible lines in proportion to
+// their value. It returns the total discount. A zero userID skips the per-user
+// limit, which lets guests preview a coupon before they log in.
+func (m *MongoDB) ApplyCoupon(c *Coupon, userID primitive.ObjectID, items []OrderItem) (money.Money, error) {
+	var none money.Money
+	for i := range items {
+		items[i].Discount = none
+	}
+
+	if !c.activeAt(time.Now()) {
+		return none, ErrCouponNotActive
+	}
+	if c.MaxUses > 0 && c.Used >= c.MaxUses {
+		return none, ErrCouponUsedUp
+	}
+	if c.MaxUsesPerUser > 0 && !userID.IsZero() {
+		used, err := m.Orders.CountDocuments(context.TODO(), bson.M{
+			"user_id":            userID,
+			"discount.coupon_id": c.ID,
+			"status":             bson.M{"$ne": "Cancelled"},
+		})
+		if err != nil {
+			return none, err
+		}
+		if int(used) >= c.MaxUsesPerUser {
+			return none, ErrCouponUsedUp
+		}
+	}
+
+	eligible, err := m.couponEligibleLines(c, items)
+	if err != nil {
+		return none, err
+	}
+
+	var base money.Money
+	weights := make([]int64, len(eligible))
+	for n, i := range eligible {
+		line := items[i].Total()
+		weights[n] = line.Amount
+		base = base.Add(line)
+	}
+	if !base.IsPositive() {
+		return none, ErrCouponNotApplicable
+	}
+	if base.Less(c.MinOrder) {
+		return none, ErrCouponMinOrder
+	}
+
+	total := c.Amount
+	if c.Type == CouponPercent {
+		total = base.Percent(c.Value)
+	}
+	total = money.Min(total, base)
+
+	for n, share := range total.Allocate(weights) {
+		items[eligible[n]].Discount = share
+	}
+	return total, nil
+}
+
+func (m *MongoDB) couponEligibleLines(c *Coupon, items []OrderItem) ([]int, error) {
+	if c.CategoryID.IsZero() && c.SellerID.IsZero() {
+		return c.eligibleLines(items, nil, nil), nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+	products, err := m.productsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := map[primitive.ObjectID]bool{}
+	if !c.CategoryID.IsZero() {
+		descendants, err := m.GetCategoryWithDescendants(c.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range descendants {
+			categories[id] = true
+		}
+	}
+	return c.eligibleLines(items, products, categories), nil
+}
+
+// eligibleLines returns the indexes of the lines within the coupon's scope.
+// categories holds the coupon's category and its descendants. Lines of
+// products that no longer exist never match a scoped coupon.
+func (c *Coupon) eligibleLines(items []OrderItem, products map[primitive.ObjectID]*Product, categories map[primitive.ObjectID]bool) []int {
+	var eligible []int
+	for i, item := range items {
+		if !c.CategoryID.IsZero() || !c.SellerID.IsZero() {
+			p, ok := products[item.ProductID]
+			if !ok {
+				continue
+			}
+			if !c.CategoryID.IsZero() && !categories[p.CategoryID] {
+				continue
+			}
+			if !c.SellerID.IsZero() && p.SellerID != c.SellerID {
+				continue
+			}
+		}
+		eligible = append(eligible, i)
+	}
+	return eligible
+}
+
+// RedeemCoupon counts one use of the coupon, failing if the global limit was
+// reached in the meantime.
+func (m *MongoDB) RedeemCoupon(id primitive.ObjectID) error {
+	filter := bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{"max_uses": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$used", "$max_uses"}}},
+		},
+	}
+	res, err := m.Coupons.UpdateOne(context.TODO(), filter, bson.M{"$inc": bson.M{"used": 1}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrCouponUsedUp
+	}
+	return nil
+}
+
+// ReleaseCoupon gives back the use of a coupon taken by a cancelled order or
+// by an order that could not be saved.
+func (m *MongoDB) ReleaseCoupon(d *OrderDiscount) error {
+	if d == nil {
+		return nil
+	}
+	_, err := m.Coupons.UpdateOne(context.TODO(), bson.M{"_id": d.CouponID, "used": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"used": -1}})
+	return err
+}
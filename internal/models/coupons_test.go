@@ -0,0 +1,163 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCouponValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Coupon
+		ok   bool
+	}{
+		{"percent", Coupon{Code: " spring10 ", Type: CouponPercent, Value: 10}, true},
+		{"fixed", Coupon{Code: "MINUS500", Type: CouponFixed, Amount: money.Tenge(500)}, true},
+		{"no code", Coupon{Code: "  ", Type: CouponPercent, Value: 10}, false},
+		{"unknown type", Coupon{Code: "X", Type: "gift", Value: 10}, false},
+		{"percent zero", Coupon{Code: "X", Type: CouponPercent}, false},
+		{"percent over 100", Coupon{Code: "X", Type: CouponPercent, Value: 101}, false},
+		{"fixed zero", Coupon{Code: "X", Type: CouponFixed}, false},
+		{"negative minimum", Coupon{Code: "X", Type: CouponPercent, Value: 5, MinOrder: money.FromMinor(-1)}, false},
+		{"negative limit", Coupon{Code: "X", Type: CouponPercent, Value: 5, MaxUses: -1}, false},
+		{"ends before start", Coupon{Code: "X", Type: CouponPercent, Value: 5,
+			StartsAt: time.Date(2026, 5, 2, 0, 0, 0, 0, time.UTC), EndsAt: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.Validate()
+			if tt.ok && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidCoupon) {
+				t.Errorf("Validate() = %v, want ErrInvalidCoupon", err)
+			}
+		})
+	}
+
+	c := Coupon{Code: " spring10 ", Type: CouponPercent, Value: 10}
+	if c.Validate(); c.Code != "SPRING10" {
+		t.Errorf("Validate() left code %q, want SPRING10", c.Code)
+	}
+}
+
+func TestCouponActiveAt(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	c := Coupon{Active: true, StartsAt: start, EndsAt: end}
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{start.Add(-time.Second), false},
+		{start, true},
+		{end.Add(-time.Second), true},
+		{end, false},
+	}
+	for _, tt := range tests {
+		if got := c.activeAt(tt.at); got != tt.want {
+			t.Errorf("activeAt(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	c.Active = false
+	if c.activeAt(start) {
+		t.Error("disabled coupon is active")
+	}
+	if !(&Coupon{Active: true}).activeAt(start) {
+		t.Error("coupon without dates is not active")
+	}
+}
+
+func TestCouponEligibleLines(t *testing.T) {
+	phones, cases, food := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	seller, other := primitive.NewObjectID(), primitive.NewObjectID()
+
+	products := map[primitive.ObjectID]*Product{}
+	var items []OrderItem
+	for _, p := range []*Product{
+		{ID: primitive.NewObjectID(), CategoryID: phones, SellerID: seller},
+		{ID: primitive.NewObjectID(), CategoryID: cases, SellerID: other},
+		{ID: primitive.NewObjectID(), CategoryID: food, SellerID: seller},
+	} {
+		products[p.ID] = p
+		items = append(items, OrderItem{ProductID: p.ID})
+	}
+	// A product deleted since it was put in the cart.
+	items = append(items, OrderItem{ProductID: primitive.NewObjectID()})
+
+	// The phones category with its cases subcategory.
+	categories := map[primitive.ObjectID]bool{phones: true, cases: true}
+
+	tests := []struct {
+		name string
+		c    Coupon
+		want []int
+	}{
+		{"unscoped", Coupon{}, []int{0, 1, 2, 3}},
+		{"category", Coupon{CategoryID: phones}, []int{0, 1}},
+		{"seller", Coupon{SellerID: seller}, []int{0, 2}},
+		{"category and seller", Coupon{CategoryID: phones, SellerID: seller}, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.eligibleLines(items, products, categories); !slices.Equal(got, tt.want) {
+				t.Errorf("eligibleLines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Unscoped coupons without a per-user limit never query the database, so
+// ApplyCoupon runs here on a nil *MongoDB.
+func TestApplyCoupon(t *testing.T) {
+	lines := func() []OrderItem {
+		return []OrderItem{
+			{Quantity: 1, UnitPrice: money.Tenge(1000)},
+			{Quantity: 3, UnitPrice: money.Tenge(1000)},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		c         Coupon
+		err       error
+		total     int64
+		discounts []int64
+	}{
+		{"percent", Coupon{Active: true, Type: CouponPercent, Value: 10}, nil, 40000, []int64{10000, 30000}},
+		{"fixed split by line value", Coupon{Active: true, Type: CouponFixed, Amount: money.FromMinor(1001)}, nil, 1001, []int64{250, 751}},
+		{"fixed capped at order", Coupon{Active: true, Type: CouponFixed, Amount: money.Tenge(9000)}, nil, 400000, []int64{100000, 300000}},
+		{"minimum met", Coupon{Active: true, Type: CouponPercent, Value: 5, MinOrder: money.Tenge(4000)}, nil, 20000, []int64{5000, 15000}},
+		{"below minimum", Coupon{Active: true, Type: CouponPercent, Value: 5, MinOrder: money.FromMinor(400001)}, ErrCouponMinOrder, 0, []int64{0, 0}},
+		{"used up", Coupon{Active: true, Type: CouponPercent, Value: 5, MaxUses: 3, Used: 3}, ErrCouponUsedUp, 0, []int64{0, 0}},
+		{"inactive", Coupon{Type: CouponPercent, Value: 5}, ErrCouponNotActive, 0, []int64{0, 0}},
+	}
+	var m *MongoDB
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := lines()
+			items[0].Discount = money.Tenge(1) // left over from an earlier coupon
+
+			total, err := m.ApplyCoupon(&tt.c, primitive.NilObjectID, items)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ApplyCoupon error = %v, want %v", err, tt.err)
+			}
+			if total.Amount != tt.total {
+				t.Errorf("ApplyCoupon total = %d, want %d", total.Amount, tt.total)
+			}
+			for i, want := range tt.discounts {
+				if items[i].Discount.Amount != want {
+					t.Errorf("line %d discount = %d, want %d", i, items[i].Discount.Amount, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,591 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrImportNotReady = errors.New("import has not been previewed or is already running")
+	ErrImportTooLarge = errors.New("import does not fit in one document")
+)
+
+// Import statuses. An upload is mapped and previewed with a dry run before
+// it can be started.
+const (
+	ImportUploaded  = "uploaded"
+	ImportPreviewed = "previewed"
+	ImportRunning   = "running"
+	ImportDone      = "done"
+	ImportFailed    = "failed"
+)
+
+const (
+	// MaxImportRows bounds an upload, which is kept in one document.
+	MaxImportRows = 5000
+
+	// maxImportSize keeps an upload well below the 16 MB document limit of
+	// MongoDB, leaving room for the report.
+	maxImportSize = 12 << 20
+
+	// attrColumnPrefix marks columns holding category attributes, such as
+	// "attr:color". They need no mapping.
+	attrColumnPrefix = "attr:"
+
+	// defaultImportStock is the stock of new products without a stock column,
+	// as in the product form.
+	defaultImportStock = 10
+
+	importPreviewRows    = 50
+	importErrorLimit     = 200
+	importProgressPeriod = 25
+)
+
+type ImportField struct {
+	Key      string
+	Label    string
+	Required bool
+	aliases  []string
+}
+
+// ImportFields are the product fields a column can be mapped to. Products
+// are matched by the seller's SKU.
+var ImportFields = []ImportField{
+	{"sku", "Артикул (SKU)", true, []string{"артикул", "код", "article"}},
+	{"name", "Атауы", false, []string{"атауы", "название", "наименование", "title"}},
+	{"price", "Бағасы", false, []string{"бағасы", "баға", "цена"}},
+	{"stock", "Қоймадағы саны", false, []string{"саны", "қойма", "остаток", "количество", "qty", "quantity"}},
+	{"city", "Қала", false, []string{"қала", "город"}},
+	{"category", "Санат", false, []string{"санат", "категория"}},
+	{"description", "Сипаттамасы", false, []string{"сипаттамасы", "описание"}},
+}
+
+type Import struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	SellerID   primitive.ObjectID `bson:"seller_id"`
+	Filename   string             `bson:"filename"`
+	Headers    []string           `bson:"headers"`
+	Rows       [][]string         `bson:"rows"`
+	Mapping    map[string]int     `bson:"mapping"`
+	Status     string             `bson:"status"`
+	Report     ImportReport       `bson:"report"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	FinishedAt time.Time          `bson:"finished_at,omitempty"`
+}
+
+// ImportReport is the outcome of a dry run or of the import itself. During
+// a dry run Created and Updated count what the import would do.
+type ImportReport struct {
+	Total     int                `bson:"total"`
+	Processed int                `bson:"processed"`
+	Created   int                `bson:"created"`
+	Updated   int                `bson:"updated"`
+	Failed    int                `bson:"failed"`
+	Errors    []ImportRowError   `bson:"errors"`
+	Preview   []ImportPreviewRow `bson:"preview,omitempty"`
+}
+
+// Progress is the share of processed rows in percent.
+func (r ImportReport) Progress() int {
+	if r.Total == 0 {
+		return 100
+	}
+	return r.Processed * 100 / r.Total
+}
+
+type ImportRowError struct {
+	Line    int    `bson:"line"`
+	SKU     string `bson:"sku"`
+	Message string `bson:"message"`
+}
+
+type ImportPreviewRow struct {
+	Line   int         `bson:"line"`
+	SKU    string      `bson:"sku"`
+	Name   string      `bson:"name"`
+	Price  money.Money `bson:"price"`
+	Stock  int         `bson:"stock"`
+	Action string      `bson:"action"`
+}
+
+// ImportRow is a planned change: a new product, or the merged state of an
+// existing one. Rows with errors are not applied.
+type ImportRow struct {
+	Line     int
+	SKU      string
+	Product  Product
+	Existing *Product
+	Errors   []string
+}
+
+func (r *ImportRow) Action() string {
+	switch {
+	case len(r.Errors) > 0:
+		return "error"
+	case r.Existing != nil:
+		return "update"
+	}
+	return "create"
+}
+
+// Column returns the column mapped to a field, or -1.
+func (imp *Import) Column(field string) int {
+	if col, ok := imp.Mapping[field]; ok {
+		return col
+	}
+	return -1
+}
+
+// AttributeColumns lists the attribute keys of the attr:* columns.
+func (imp *Import) AttributeColumns() []string {
+	var keys []string
+	for _, h := range imp.Headers {
+		if key, ok := strings.CutPrefix(strings.TrimSpace(h), attrColumnPrefix); ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GuessImportMapping maps the columns whose headers name a field, in any of
+// the languages sellers use.
+func GuessImportMapping(headers []string) map[string]int {
+	mapping := map[string]int{}
+	for _, f := range ImportFields {
+		names := append([]string{f.Key, strings.ToLower(f.Label)}, f.aliases...)
+		for i, h := range headers {
+			h = strings.ToLower(strings.TrimSpace(h))
+			if _, taken := mapping[f.Key]; taken {
+				break
+			}
+			for _, name := range names {
+				if h == name {
+					mapping[f.Key] = i
+					break
+				}
+			}
+		}
+	}
+	return mapping
+}
+
+// CreateImport stores an upload. Uploads whose cells add up to more than
+// fits in a document give ErrImportTooLarge.
+func (m *MongoDB) CreateImport(imp *Import) error {
+	imp.ID = primitive.NewObjectID()
+	imp.Status = ImportUploaded
+	imp.CreatedAt = time.Now()
+	doc, err := bson.Marshal(imp)
+	if err != nil {
+		return err
+	}
+	if len(doc) > maxImportSize {
+		return ErrImportTooLarge
+	}
+	_, err = m.Imports.InsertOne(context.TODO(), bson.Raw(doc))
+	return err
+}
+
+func (m *MongoDB) GetImport(id, sellerID primitive.ObjectID) (*Import, error) {
+	var imp Import
+	err := m.Imports.FindOne(context.TODO(), bson.M{"_id": id, "seller_id": sellerID}).Decode(&imp)
+	return &imp, err
+}
+
+// GetSellerImports lists the latest imports without their rows.
+func (m *MongoDB) GetSellerImports(sellerID primitive.ObjectID, limit int64) ([]*Import, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit).SetProjection(bson.M{"rows": 0})
+	return m.findImports(bson.M{"seller_id": sellerID}, opts)
+}
+
+func (m *MongoDB) findImports(filter bson.M, opts *options.FindOptions) ([]*Import, error) {
+	var imports []*Import
+	cur, err := m.Imports.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &imports)
+	return imports, err
+}
+
+// PreviewImport is the dry run: it plans every row with the given mapping
+// and stores the report without touching any product.
+func (m *MongoDB) PreviewImport(imp *Import, mapping map[string]int) error {
+	if imp.Status != ImportUploaded && imp.Status != ImportPreviewed {
+		return ErrImportNotReady
+	}
+	imp.Mapping = mapping
+
+	rows, err := m.PlanImport(imp)
+	if err != nil {
+		return err
+	}
+	report := ImportReport{Total: len(rows)}
+	for _, row := range rows {
+		report.add(row)
+		if len(report.Preview) < importPreviewRows {
+			report.Preview = append(report.Preview, ImportPreviewRow{
+				Line:   row.Line,
+				SKU:    row.SKU,
+				Name:   row.Product.Name,
+				Price:  row.Product.Price,
+				Stock:  row.Product.Stock,
+				Action: row.Action(),
+			})
+		}
+	}
+	imp.Status = ImportPreviewed
+	imp.Report = report
+
+	_, err = m.Imports.UpdateOne(context.TODO(), bson.M{"_id": imp.ID}, bson.M{"$set": bson.M{
+		"mapping": mapping,
+		"status":  imp.Status,
+		"report":  report,
+	}})
+	return err
+}
+
+func (r *ImportReport) add(row *ImportRow) {
+	switch row.Action() {
+	case "create":
+		r.Created++
+	case "update":
+		r.Updated++
+	default:
+		r.Failed++
+		if len(r.Errors) < importErrorLimit {
+			r.Errors = append(r.Errors, ImportRowError{Line: row.Line, SKU: row.SKU, Message: strings.Join(row.Errors, "; ")})
+		}
+	}
+}
+
+// StartImport marks a previewed import as running.
+func (m *MongoDB) StartImport(id, sellerID primitive.ObjectID) (*Import, error) {
+	filter := bson.M{"_id": id, "seller_id": sellerID, "status": ImportPreviewed}
+	res, err := m.Imports.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"status": ImportRunning}})
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrImportNotReady
+	}
+	return m.GetImport(id, sellerID)
+}
+
+// GetRunningImports returns the imports that were interrupted by a restart.
+func (m *MongoDB) GetRunningImports() ([]*Import, error) {
+	return m.findImports(bson.M{"status": ImportRunning}, nil)
+}
+
+// RunImport plans the rows again, so that changes since the dry run are
+// taken into account, and applies the valid ones. Applying is idempotent,
+// so an interrupted import can simply be run again.
+func (m *MongoDB) RunImport(imp *Import) error {
+	rows, err := m.PlanImport(imp)
+	if err != nil {
+		m.finishImport(imp.ID, ImportFailed, imp.Report)
+		return err
+	}
+
+	report := ImportReport{Total: len(rows)}
+	for i, row := range rows {
+		if row.Action() != "error" {
+			if err := m.applyImportRow(row); err != nil {
+				row.Errors = append(row.Errors, "Сақтау қатесі")
+				report.add(row)
+				report.Processed = i + 1
+				m.finishImport(imp.ID, ImportFailed, report)
+				return err
+			}
+		}
+		report.add(row)
+		report.Processed = i + 1
+
+		if report.Processed%importProgressPeriod == 0 {
+			_, err := m.Imports.UpdateOne(context.TODO(), bson.M{"_id": imp.ID}, bson.M{"$set": bson.M{"report": report}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return m.finishImport(imp.ID, ImportDone, report)
+}
+
+func (m *MongoDB) finishImport(id primitive.ObjectID, status string, report ImportReport) error {
+	_, err := m.Imports.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":      status,
+		"report":      report,
+		"finished_at": time.Now(),
+	}})
+	return err
+}
+
+// PlanImport validates every non-empty row against the mapping and the
+// seller's catalog. Cells left empty keep the current value of an existing
+// product.
+func (m *MongoDB) PlanImport(imp *Import) ([]*ImportRow, error) {
+	products, err := m.GetProductsBySeller(imp.SellerID)
+	if err != nil {
+		return nil, err
+	}
+	bySKU := map[string]*Product{}
+	for _, p := range products {
+		if p.SKU != "" {
+			bySKU[p.SKU] = p
+		} else {
+			// Exports use the ID for products without a SKU.
+			bySKU[p.ID.Hex()] = p
+		}
+	}
+
+	categories, err := m.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	categoryByID := map[primitive.ObjectID]*Category{}
+	categoryByName := map[string]*Category{}
+	for _, c := range categories {
+		categoryByID[c.ID] = c
+		categoryByName[strings.ToLower(c.Slug)] = c
+		categoryByName[strings.ToLower(c.Name)] = c
+		categoryByName[c.ID.Hex()] = c
+	}
+
+	attrColumns := map[string]int{}
+	for i, h := range imp.Headers {
+		if key, ok := strings.CutPrefix(strings.TrimSpace(h), attrColumnPrefix); ok && key != "" {
+			attrColumns[key] = i
+		}
+	}
+
+	var plan []*ImportRow
+	seen := map[string]int{}
+	for i, cells := range imp.Rows {
+		cell := func(field string) string {
+			col, ok := imp.Mapping[field]
+			if !ok || col < 0 || col >= len(cells) {
+				return ""
+			}
+			return strings.TrimSpace(cells[col])
+		}
+		if blankRow(cells) {
+			continue
+		}
+
+		// Line numbers count the header, as spreadsheet programs do.
+		row := &ImportRow{Line: i + 2, SKU: cell("sku")}
+		plan = append(plan, row)
+		fail := func(format string, args ...interface{}) {
+			row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+		}
+
+		if row.SKU == "" {
+			fail("Артикул толтырылмаған")
+			continue
+		}
+		if line, ok := seen[row.SKU]; ok {
+			fail("Артикул файлда қайталанады (%d-жол)", line)
+			continue
+		}
+		seen[row.SKU] = row.Line
+
+		p := Product{SKU: row.SKU, SellerID: imp.SellerID, Stock: defaultImportStock}
+		if existing, ok := bySKU[row.SKU]; ok {
+			row.Existing = existing
+			p = *existing
+			p.SKU = row.SKU
+			if existing.OnSale() {
+				p.Price = existing.OriginalPrice
+			}
+		}
+		isNew := row.Existing == nil
+
+		if v := cell("name"); v != "" {
+			p.Name = v
+		} else if isNew {
+			fail("Атауы толтырылмаған")
+		}
+
+		if v := cell("price"); v != "" {
+			price, err := money.Parse(v)
+			if err != nil || !price.IsPositive() {
+				fail("Баға қате: %s", v)
+			}
+			p.Price = price
+		} else if isNew {
+			fail("Баға толтырылмаған")
+		}
+
+		if v := cell("stock"); v != "" {
+			stock, err := strconv.Atoi(v)
+			if err != nil || stock < 0 {
+				fail("Саны қате: %s", v)
+			}
+			p.Stock = stock
+		}
+
+		if v := cell("city"); v != "" {
+			p.City = v
+		} else if isNew {
+			fail("Қала толтырылмаған")
+		}
+
+		if v := cell("description"); v != "" {
+			p.Description = v
+		}
+
+		category := categoryByID[p.CategoryID]
+		if v := cell("category"); v != "" {
+			category = categoryByName[strings.ToLower(v)]
+			if category == nil {
+				fail("Санат табылмады: %s", v)
+			}
+		} else if isNew {
+			fail("Санат толтырылмаған")
+		}
+
+		if category != nil {
+			p.CategoryID = category.ID
+			values := map[string]string{}
+			for k, v := range p.Attributes {
+				values[k] = v
+			}
+			for key, col := range attrColumns {
+				if col < len(cells) && strings.TrimSpace(cells[col]) != "" {
+					values[key] = cells[col]
+				}
+			}
+			attrs, err := ValidateAttributes(category.Schema, values)
+			var attrErr *AttributeError
+			if errors.As(err, &attrErr) {
+				fail("Сипаттама толтырылмаған немесе қате: %s", attrErr.Label)
+			}
+			p.Attributes = attrs
+		}
+
+		row.Product = p
+	}
+	return plan, nil
+}
+
+func blankRow(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// applyImportRow creates or updates the product of a row. New products are
+// upserted by seller and SKU, so a product created by another import since
+// the plan was made is updated instead of duplicated.
+func (m *MongoDB) applyImportRow(row *ImportRow) error {
+	p := row.Product
+	current := row.Existing
+	if current == nil {
+		p.ID = primitive.NewObjectID()
+		filter := bson.M{"seller_id": p.SellerID, "sku": p.SKU}
+		res, err := m.Products.UpdateOne(context.TODO(), filter, bson.M{"$setOnInsert": p}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+		if res.UpsertedCount > 0 {
+			return m.RecordPriceChange(p.ID, p.Price, PriceChangeCreated)
+		}
+		current = &Product{}
+		if err := m.Products.FindOne(context.TODO(), filter).Decode(current); err != nil {
+			return err
+		}
+	}
+
+	fields := bson.M{
+		"sku":         p.SKU,
+		"name":        p.Name,
+		"stock":       p.Stock,
+		"city":        p.City,
+		"description": p.Description,
+		"category_id": p.CategoryID,
+		"attributes":  p.Attributes,
+	}
+	// As in UpdateProduct, a running sale keeps its price and the new one
+	// applies when it ends.
+	if current.OnSale() {
+		fields["original_price"] = p.Price
+	} else {
+		fields["price"] = p.Price
+	}
+	_, err := m.Products.UpdateOne(context.TODO(), bson.M{"_id": current.ID}, bson.M{"$set": fields})
+	if err != nil || current.OnSale() || current.Price == p.Price {
+		return err
+	}
+	return m.RecordPriceChange(current.ID, p.Price, PriceChangeManual)
+}
+
+// ExportProducts lays the seller's catalog out in the import format, so an
+// edited export can be uploaded again.
+func (m *MongoDB) ExportProducts(sellerID primitive.ObjectID) ([][]string, error) {
+	products, err := m.GetProductsBySeller(sellerID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := m.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	slugs := map[primitive.ObjectID]string{}
+	for _, c := range categories {
+		slugs[c.ID] = c.Slug
+	}
+
+	keySet := map[string]bool{}
+	for _, p := range products {
+		for k := range p.Attributes {
+			keySet[k] = true
+		}
+	}
+	var keys []string
+	for k := range keySet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	header := make([]string, 0, len(ImportFields)+len(keys))
+	for _, f := range ImportFields {
+		header = append(header, f.Key)
+	}
+	for _, k := range keys {
+		header = append(header, attrColumnPrefix+k)
+	}
+
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+	rows := [][]string{header}
+	for _, p := range products {
+		sku := p.SKU
+		if sku == "" {
+			sku = p.ID.Hex()
+		}
+		price := p.Price
+		if p.OnSale() {
+			price = p.OriginalPrice
+		}
+		row := []string{sku, p.Name, price.Decimal(), strconv.Itoa(p.Stock), p.City, slugs[p.CategoryID], p.Description}
+		for _, k := range keys {
+			row = append(row, p.Attributes[k])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// toMoney builds an aggregation expression turning a float amount in tenge
+// into a money document. Values that are already converted are kept as is,
+// so the migration can be run again safely.
+func toMoney(expr interface{}) bson.M {
+	return bson.M{"$cond": bson.A{
+		bson.M{"$isNumber": expr},
+		bson.M{
+			"amount":   bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{expr, 100}}, 0}}},
+			"currency": money.KZT,
+		},
+		expr,
+	}}
+}
+
+func migrateMoneyFields(coll *mongo.Collection, fields ...string) (int64, error) {
+	var total int64
+	for _, f := range fields {
+		pipeline := bson.A{bson.M{"$set": bson.M{f: toMoney("$" + f)}}}
+		res, err := coll.UpdateMany(context.TODO(), bson.M{f: bson.M{"$type": "number"}}, pipeline)
+		if err != nil {
+			return total, err
+		}
+		total += res.ModifiedCount
+	}
+	return total, nil
+}
+
+// MigrateMoney converts amounts stored as floating point tenge into money
+// documents with integer tiyn. It returns the number of updated documents.
+func (m *MongoDB) MigrateMoney() (int64, error) {
+	steps := []struct {
+		coll   *mongo.Collection
+		fields []string
+	}{
+		{m.Products, []string{"price", "original_price", "sale.price"}},
+		{m.Carts, []string{"price"}},
+		{m.Orders, []string{"subtotal", "total_price", "discount.amount"}},
+		{m.Payments, []string{"amount"}},
+		{m.Returns, []string{"amount"}},
+		{m.Wishlists, []string{"price"}},
+		{m.PriceHistory, []string{"price"}},
+		{m.Coupons, []string{"min_order"}},
+	}
+
+	var total int64
+	for _, s := range steps {
+		n, err := migrateMoneyFields(s.coll, s.fields...)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	// Order lines live in an array, so they are rewritten one by one.
+	items := bson.M{"$map": bson.M{
+		"input": "$items",
+		"as":    "it",
+		"in": bson.M{"$mergeObjects": bson.A{"$$it", bson.M{
+			"unitprice": toMoney("$$it.unitprice"),
+			"discount":  toMoney(bson.M{"$ifNull": bson.A{"$$it.discount", 0}}),
+		}}},
+	}}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"items.unitprice": bson.M{"$type": "number"}},
+		bson.M{"items.discount": bson.M{"$exists": false}, "items.0": bson.M{"$exists": true}},
+	}}
+	res, err := m.Orders.UpdateMany(context.TODO(), filter, bson.A{bson.M{"$set": bson.M{"items": items}}})
+	if err != nil {
+		return total, err
+	}
+	total += res.ModifiedCount
+
+	// Fixed coupons kept their tenge amount in value.
+	res, err = m.Coupons.UpdateMany(context.TODO(),
+		bson.M{"type": CouponFixed, "value": bson.M{"$type": "number"}},
+		bson.A{
+			bson.M{"$set": bson.M{"amount": toMoney("$value")}},
+			bson.M{"$unset": "value"},
+		})
+	if err != nil {
+		return total, err
+	}
+	total += res.ModifiedCount
+
+	return total, nil
+}
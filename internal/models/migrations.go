@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a versioned change of the stored data. Applied versions are
+// recorded in the migrations collection, so each runs once. Migrations must
+// still be safe to repeat: two servers starting together may both run one.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(m *MongoDB) error
+}
+
+type appliedMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// Migrations are applied in order of version. Never change or renumber one
+// that has been released; add a new one instead.
+var Migrations = []Migration{
+	{1, "money-in-tiyn", func(m *MongoDB) error {
+		_, err := m.MigrateMoney()
+		return err
+	}},
+	{2, "snake-case-ids", (*MongoDB).migrateSnakeCaseIDs},
+	{3, "category-path-and-slug", (*MongoDB).backfillCategories},
+	{4, "cart-updated-at", (*MongoDB).backfillCartUpdatedAt},
+	{5, "unique-seller-sku", (*MongoDB).dropPlainSKUIndex},
+}
+
+// Migrate applies the migrations that have not run yet and returns their
+// names.
+func (m *MongoDB) Migrate() ([]string, error) {
+	return m.runMigrations(false)
+}
+
+// RerunMigrations applies every migration again, including those already
+// recorded. Data imported from an export of an older version needs this,
+// since its documents predate the recorded migrations.
+func (m *MongoDB) RerunMigrations() ([]string, error) {
+	return m.runMigrations(true)
+}
+
+func (m *MongoDB) runMigrations(all bool) ([]string, error) {
+	cur, err := m.Migrations.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var done []appliedMigration
+	if err := cur.All(context.TODO(), &done); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(done))
+	for _, d := range done {
+		applied[d.Version] = true
+	}
+
+	var names []string
+	for _, mig := range Migrations {
+		if applied[mig.Version] && !all {
+			continue
+		}
+		if err := mig.Up(m); err != nil {
+			return names, fmt.Errorf("migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+		names = append(names, mig.Name)
+		if applied[mig.Version] {
+			continue
+		}
+		record := appliedMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}
+		_, err := m.Migrations.InsertOne(context.TODO(), record)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return names, err
+		}
+	}
+	return names, nil
+}
+
+// migrateSnakeCaseIDs renames the user and product references of orders and
+// reviews, stored as "userid" and "productid", to "user_id" and "product_id"
+// like in the other collections.
+func (m *MongoDB) migrateSnakeCaseIDs() error {
+	renames := []struct {
+		coll     *mongo.Collection
+		from, to string
+	}{
+		{m.Orders, "userid", "user_id"},
+		{m.Reviews, "userid", "user_id"},
+		{m.Reviews, "productid", "product_id"},
+	}
+	for _, r := range renames {
+		_, err := r.coll.UpdateMany(context.TODO(), bson.M{r.from: bson.M{"$exists": true}}, bson.M{"$rename": bson.M{r.from: r.to}})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Fields inside arrays cannot be renamed, so the elements are rewritten.
+	arrays := []struct {
+		coll            *mongo.Collection
+		array, from, to string
+	}{
+		{m.Orders, "items", "productid", "product_id"},
+		{m.Reviews, "reports", "userid", "user_id"},
+	}
+	for _, a := range arrays {
+		elements := bson.M{"$map": bson.M{
+			"input": "$" + a.array,
+			"as":    "el",
+			"in":    bson.M{"$mergeObjects": bson.A{"$$el", bson.M{a.to: "$$el." + a.from}}},
+		}}
+		pipeline := bson.A{
+			bson.M{"$set": bson.M{a.array: elements}},
+			bson.M{"$unset": a.array + "." + a.from},
+		}
+		_, err := a.coll.UpdateMany(context.TODO(), bson.M{a.array + "." + a.from: bson.M{"$exists": true}}, pipeline)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropPlainSKUIndex drops the index on the seller and SKU of products from
+// before it was unique, so that EnsureIndexes can create the unique one under
+// the same name.
+func (m *MongoDB) dropPlainSKUIndex() error {
+	cur, err := m.Products.Indexes().List(context.TODO())
+	if err != nil {
+		return err
+	}
+	var indexes []struct {
+		Name   string `bson:"name"`
+		Unique bool   `bson:"unique"`
+	}
+	if err := cur.All(context.TODO(), &indexes); err != nil {
+		return err
+	}
+	for _, ix := range indexes {
+		if ix.Name == "seller_id_1_sku_1" && !ix.Unique {
+			_, err := m.Products.Indexes().DropOne(context.TODO(), ix.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureIndexes creates the indexes the queries rely on. Existing indexes are
+// left alone. All indexes are attempted even if some fail, for example a
+// unique index over duplicate data, and the failures are returned together.
+func (m *MongoDB) EnsureIndexes() error {
+	unique := options.Index().SetUnique(true)
+	indexes := []struct {
+		coll  *mongo.Collection
+		model mongo.IndexModel
+	}{
+		{m.Users, mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: unique}},
+		{m.Products, mongo.IndexModel{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "sku", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$exists": true}})}},
+		{m.Products, mongo.IndexModel{Keys: bson.D{{Key: "category_id", Value: 1}}}},
+		{m.Products, mongo.IndexModel{Keys: bson.D{{Key: "city", Value: 1}, {Key: "popularity", Value: -1}}}},
+		{m.Products, mongo.IndexModel{Keys: bson.D{{Key: "popularity", Value: -1}}}},
+		{m.Carts, mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}}},
+		{m.Carts, mongo.IndexModel{Keys: bson.D{{Key: "guest_id", Value: 1}}}},
+		{m.Carts, mongo.IndexModel{Keys: bson.D{{Key: "updated_at", Value: 1}}}},
+		{m.Orders, mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}}},
+		{m.Orders, mongo.IndexModel{Keys: bson.D{{Key: "items.product_id", Value: 1}}}},
+		{m.Orders, mongo.IndexModel{Keys: bson.D{{Key: "items.seller_id", Value: 1}, {Key: "created_at", Value: -1}}}},
+		{m.Orders, mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}}},
+		{m.Reviews, mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "status", Value: 1}}}},
+		{m.Reviews, mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "product_id", Value: 1}}, Options: unique}},
+		{m.Payments, mongo.IndexModel{Keys: bson.D{{Key: "order_id", Value: 1}}}},
+		{m.Returns, mongo.IndexModel{Keys: bson.D{{Key: "order_id", Value: 1}}}},
+		{m.Wishlists, mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "product_id", Value: 1}}, Options: unique}},
+		{m.Notifications, mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}}},
+		{m.Coupons, mongo.IndexModel{Keys: bson.D{{Key: "code", Value: 1}}, Options: unique}},
+		{m.PriceHistory, mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "changed_at", Value: -1}}}},
+		{m.ProductStats, mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "day", Value: 1}}, Options: unique}},
+		{m.ProductStats, mongo.IndexModel{Keys: bson.D{{Key: "day", Value: 1}}}},
+		{m.Imports, mongo.IndexModel{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "created_at", Value: -1}}}},
+		{m.Imports, mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}}}},
+	}
+
+	var errs []error
+	for _, ix := range indexes {
+		if _, err := ix.coll.Indexes().CreateOne(context.TODO(), ix.model); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ix.coll.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
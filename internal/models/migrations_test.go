@@ -0,0 +1,21 @@
+package models
+
+import "testing"
+
+// Versions are recorded once applied, so they must be unique and in order,
+// and a gap would usually mean a migration was renumbered.
+func TestMigrationsOrdered(t *testing.T) {
+	names := map[string]bool{}
+	for i, mig := range Migrations {
+		if mig.Version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", mig.Name, mig.Version, i+1)
+		}
+		if mig.Name == "" || names[mig.Name] {
+			t.Errorf("migration %d has an empty or repeated name %q", mig.Version, mig.Name)
+		}
+		names[mig.Name] = true
+		if mig.Up == nil {
+			t.Errorf("migration %d %s has no Up", mig.Version, mig.Name)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"kazakh_aliexpress/internal/money"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -12,61 +14,129 @@ type User struct {
 	PasswordHash string             `bson:"password_hash"`
 	Role         string             `bson:"role"`
 	CreatedAt    time.Time          `bson:"created_at"`
+	Seller       *SellerInfo        `bson:"seller,omitempty"`
 }
 
 type Review struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	ProductID primitive.ObjectID
-	UserID    primitive.ObjectID
-	Rating    int
-	Comment   string
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	ProductID   primitive.ObjectID `bson:"product_id"`
+	UserID      primitive.ObjectID `bson:"user_id"`
+	Rating      int
+	Comment     string
+	Verified    bool
+	Status      string
+	Flags       []string
+	Reports     []ReviewReport
+	Reply       *SellerReply
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ModeratedAt time.Time `bson:"moderated_at,omitempty"`
+}
+
+type SellerReply struct {
+	Text      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ReviewReport struct {
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Reason    string
 	CreatedAt time.Time
 }
 
 type Order struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty"`
-	UserID        primitive.ObjectID `bson:"userid"`
+	UserID        primitive.ObjectID `bson:"user_id"`
 	Status        string             `bson:"status"`
-	TotalPrice    float64            `bson:"total_price"`
+	Subtotal      money.Money        `bson:"subtotal"`
+	Discount      *OrderDiscount     `bson:"discount,omitempty"`
+	Tax           money.Money        `bson:"tax"`
+	TaxInclusive  bool               `bson:"tax_inclusive"`
+	TotalPrice    money.Money        `bson:"total_price"`
 	PaymentMethod string             `bson:"payment_method"`
 	Items         []OrderItem        `bson:"items"`
 	CreatedAt     time.Time          `bson:"created_at"`
 }
 
 type OrderItem struct {
-	ProductID primitive.ObjectID `bson:"productid"`
+	ProductID primitive.ObjectID `bson:"product_id"`
+	Name      string             `bson:"name"`
 	Quantity  int                `bson:"quantity"`
-	UnitPrice float64            `bson:"unitprice"`
+	UnitPrice money.Money        `bson:"unitprice"`
+	Discount  money.Money        `bson:"discount"`
+	SellerID  primitive.ObjectID `bson:"seller_id,omitempty"`
+	TaxRate   float64            `bson:"tax_rate"`
+	Tax       money.Money        `bson:"tax"`
+}
+
+// Total is the line's price before the order discount.
+func (i OrderItem) Total() money.Money {
+	return i.UnitPrice.Mul(i.Quantity)
+}
+
+// PaidFor is what the customer actually paid for qty units of the line once
+// its share of the order discount is taken into account. Tax that was added
+// on top of the price, when the order is not tax inclusive, is paid as well.
+func (i OrderItem) PaidFor(qty int, taxInclusive bool) money.Money {
+	paid := i.Total().Sub(i.Discount)
+	if !taxInclusive {
+		paid = paid.Add(i.Tax)
+	}
+	return paid.Share(int64(qty), int64(i.Quantity))
 }
 
 type Category struct {
-	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name string             `bson:"name" json:"name"`
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name       string               `bson:"name" json:"name"`
+	Slug       string               `bson:"slug" json:"slug"`
+	ParentID   primitive.ObjectID   `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	Path       []primitive.ObjectID `bson:"path" json:"path"`
+	SortOrder  int                  `bson:"sort_order" json:"sort_order"`
+	Attributes []AttributeDef       `bson:"attributes,omitempty" json:"attributes,omitempty"`
+	Schema     []AttributeDef       `bson:"-" json:"-"`
 }
 
 type Payment struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	OrderID   primitive.ObjectID `bson:"order_id" json:"order_id"`
-	Amount    float64            `bson:"amount" json:"amount"`
+	Amount    money.Money        `bson:"amount" json:"amount"`
 	Status    string             `bson:"status" json:"status"`
 	Method    string             `bson:"method" json:"method"`
+	RefundOf  primitive.ObjectID `bson:"refund_of,omitempty" json:"refund_of,omitempty"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 }
 
 type Product struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name        string             `bson:"name" json:"name"`
-	Price       float64            `bson:"price" json:"price"`
-	Stock       int                `bson:"stock" json:"stock"`
-	City        string             `bson:"city" json:"city"`
-	CategoryID  primitive.ObjectID `bson:"category_id" json:"category_id"`
-	SellerID    primitive.ObjectID `bson:"seller_id" json:"seller_id"`
-	Description string             `bson:"description" json:"description"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SKU          string             `bson:"sku,omitempty" json:"sku,omitempty"`
+	Name         string             `bson:"name" json:"name"`
+	Price        money.Money        `bson:"price" json:"price"`
+	Stock        int                `bson:"stock" json:"stock"`
+	City         string             `bson:"city" json:"city"`
+	CategoryID   primitive.ObjectID `bson:"category_id" json:"category_id"`
+	SellerID     primitive.ObjectID `bson:"seller_id" json:"seller_id"`
+	Description  string             `bson:"description" json:"description"`
+	RatingAvg    float64            `bson:"rating_avg" json:"rating_avg"`
+	RatingCount  int                `bson:"rating_count" json:"rating_count"`
+	RatingCounts [MaxRating]int     `bson:"rating_counts" json:"rating_counts"`
+	Attributes   map[string]string  `bson:"attributes,omitempty" json:"attributes,omitempty"`
+	Popularity   float64            `bson:"popularity" json:"popularity"`
+
+	OriginalPrice money.Money `bson:"original_price,omitempty" json:"original_price,omitempty"`
+	Sale          *Sale       `bson:"sale,omitempty" json:"sale,omitempty"`
 }
 
 type Cart struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty"`
-	UserID     primitive.ObjectID `bson:"user_id"`
-	Items      []*CartItem        `bson:"-"`
-	TotalPrice float64            `bson:"total_price"`
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	UserID       primitive.ObjectID `bson:"user_id"`
+	Items        []*CartItem        `bson:"-"`
+	TotalPrice   money.Money        `bson:"total_price"`
+	HasIssues    bool               `bson:"-"`
+	Subtotal     money.Money        `bson:"-"`
+	Discount     money.Money        `bson:"-"`
+	Tax          money.Money        `bson:"-"`
+	TaxInclusive bool               `bson:"-"`
+	CouponCode   string             `bson:"-"`
+	CouponError  string             `bson:"-"`
 }
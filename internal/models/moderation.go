@@ -0,0 +1,124 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrAlreadyReported     = errors.New("review already reported by this user")
+	ErrOwnReview           = errors.New("users cannot report their own review")
+	ErrInvalidReportReason = errors.New("unknown report reason")
+)
+
+type ReportReason struct {
+	Key   string
+	Label string
+}
+
+// ReportReasons are the reasons a review can be reported for. Reports store
+// the key.
+var ReportReasons = []ReportReason{
+	{"spam", "Спам немесе жарнама"},
+	{"offensive", "Балағат немесе қорлау"},
+	{"off_topic", "Тауарға қатысы жоқ"},
+	{"personal_data", "Жеке деректер"},
+	{"fake", "Жалған пікір"},
+}
+
+// ReasonLabel returns the label of the report reason. Reports made before
+// the reasons were fixed keep their free text.
+func (r ReviewReport) ReasonLabel() string {
+	for _, reason := range ReportReasons {
+		if reason.Key == r.Reason {
+			return reason.Label
+		}
+	}
+	return r.Reason
+}
+
+// Reviews published within this window show up in the moderation queue even
+// when nobody has reported them.
+const newReviewWindow = 7 * 24 * time.Hour
+
+// ReportReview adds the user's report to a review. reason must be the key of
+// one of ReportReasons.
+func (m *MongoDB) ReportReview(id, userID primitive.ObjectID, reason string) error {
+	if !slices.ContainsFunc(ReportReasons, func(r ReportReason) bool { return r.Key == reason }) {
+		return ErrInvalidReportReason
+	}
+
+	review, err := m.GetReview(id)
+	if err != nil {
+		return err
+	}
+	if review.UserID == userID {
+		return ErrOwnReview
+	}
+
+	filter := bson.M{"_id": id, "reports.user_id": bson.M{"$ne": userID}}
+	update := bson.M{"$push": bson.M{"reports": ReviewReport{
+		UserID:    userID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}}}
+
+	res, err := m.Reviews.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrAlreadyReported
+	}
+	return nil
+}
+
+// moderationQueueFilter matches the reviews held by the filter, the reported
+// ones that are still visible, and the recent ones no moderator has looked at.
+func moderationQueueFilter(now time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"status": ReviewPending},
+		{"reports.0": bson.M{"$exists": true}, "status": bson.M{"$ne": ReviewHidden}},
+		{"createdat": bson.M{"$gte": now.Add(-newReviewWindow)}, "status": publicReview, "moderated_at": bson.M{"$exists": false}},
+	}}
+}
+
+func (m *MongoDB) GetModerationQueue() ([]*Review, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}})
+
+	var reviews []*Review
+	cur, err := m.Reviews.Find(context.TODO(), moderationQueueFilter(time.Now()), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &reviews)
+	return reviews, err
+}
+
+// SetReviewStatus publishes or hides a review and records that a moderator
+// has seen it. Approving also clears its reports so it leaves the moderation
+// queue.
+func (m *MongoDB) SetReviewStatus(id primitive.ObjectID, status string) error {
+	set := bson.M{"status": status, "moderated_at": time.Now()}
+	if status == ReviewPublished {
+		set["reports"] = []ReviewReport{}
+		set["flags"] = []string{}
+	}
+
+	return m.withTransaction(func(ctx mongo.SessionContext) error {
+		var r Review
+		err := m.Reviews.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": set}).Decode(&r)
+		if err != nil {
+			return err
+		}
+		return m.recomputeRating(ctx, r.ProductID)
+	})
+}
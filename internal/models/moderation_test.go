@@ -0,0 +1,47 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestModerationQueueFilter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	clauses := moderationQueueFilter(now)["$or"].([]bson.M)
+	if len(clauses) != 3 {
+		t.Fatalf("queue filter has %d clauses, want 3", len(clauses))
+	}
+
+	// Reviews a moderator has approved leave the queue even while they are
+	// new, and hidden ones stay out even when reported again.
+	recent := bson.M{
+		"createdat":    bson.M{"$gte": time.Date(2026, 10, 11, 12, 0, 0, 0, time.UTC)},
+		"status":       publicReview,
+		"moderated_at": bson.M{"$exists": false},
+	}
+	if !reflect.DeepEqual(clauses[2], recent) {
+		t.Errorf("new review clause = %v, want %v", clauses[2], recent)
+	}
+	if got := clauses[1]["status"]; !reflect.DeepEqual(got, bson.M{"$ne": ReviewHidden}) {
+		t.Errorf("reported clause status = %v, want hidden excluded", got)
+	}
+}
+
+func TestReasonLabel(t *testing.T) {
+	tests := []struct {
+		reason, want string
+	}{
+		{"spam", "Спам немесе жарнама"},
+		{"fake", "Жалған пікір"},
+		{"Бағасы дұрыс емес", "Бағасы дұрыс емес"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := (ReviewReport{Reason: tt.reason}).ReasonLabel(); got != tt.want {
+			t.Errorf("ReasonLabel(%q) = %q, want %q", tt.reason, got, tt.want)
+		}
+	}
+}
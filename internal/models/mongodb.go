@@ -4,19 +4,55 @@ import (
 	"context"
 	"time"
 
+	"kazakh_aliexpress/internal/money"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoDB struct {
-	Products   *mongo.Collection
-	Reviews    *mongo.Collection
-	Users      *mongo.Collection
-	Orders     *mongo.Collection
-	Categories *mongo.Collection
-	Payments   *mongo.Collection
-	Carts      *mongo.Collection
+	Products        *mongo.Collection
+	Reviews         *mongo.Collection
+	Users           *mongo.Collection
+	Orders          *mongo.Collection
+	Categories      *mongo.Collection
+	Payments        *mongo.Collection
+	Carts           *mongo.Collection
+	Returns         *mongo.Collection
+	Wishlists       *mongo.Collection
+	Notifications   *mongo.Collection
+	Coupons         *mongo.Collection
+	PriceHistory    *mongo.Collection
+	ProductStats    *mongo.Collection
+	Recommendations *mongo.Collection
+	RecentlyViewed  *mongo.Collection
+	Imports         *mongo.Collection
+	Migrations      *mongo.Collection
+}
+
+// NewMongoDB binds the collections of the database.
+func NewMongoDB(db *mongo.Database) *MongoDB {
+	return &MongoDB{
+		Products:        db.Collection("products"),
+		Reviews:         db.Collection("reviews"),
+		Users:           db.Collection("users"),
+		Orders:          db.Collection("orders"),
+		Categories:      db.Collection("categories"),
+		Payments:        db.Collection("payments"),
+		Returns:         db.Collection("returns"),
+		Carts:           db.Collection("cart"),
+		Wishlists:       db.Collection("wishlists"),
+		Notifications:   db.Collection("notifications"),
+		Coupons:         db.Collection("coupons"),
+		PriceHistory:    db.Collection("price_history"),
+		ProductStats:    db.Collection("product_stats"),
+		Recommendations: db.Collection("recommendations"),
+		RecentlyViewed:  db.Collection("recently_viewed"),
+		Imports:         db.Collection("imports"),
+		Migrations:      db.Collection("migrations"),
+	}
 }
 
 func (m *MongoDB) GetProductByOID(id primitive.ObjectID) (*Product, error) {
@@ -76,13 +112,6 @@ func (m *MongoDB) CreateOrder(o Order) error {
 	return err
 }
 
-func (m *MongoDB) GetCartByUserID(userID primitive.ObjectID) (*Cart, error) {
-	var c Cart
-	collection := m.Users.Database().Collection("cart")
-	err := collection.FindOne(context.TODO(), bson.M{"user_id": userID}).Decode(&c)
-	return &c, err
-}
-
 func (m *MongoDB) GetOrder(id primitive.ObjectID) (*Order, error) {
 	var o Order
 	err := m.Orders.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&o)
@@ -105,45 +134,32 @@ func (m *MongoDB) UpdateOrderStatus(orderID primitive.ObjectID, status string) e
 	return err
 }
 
-func (m *MongoDB) GetTotalRevenue() (float64, error) {
+func (m *MongoDB) GetTotalRevenue() (money.Money, error) {
 	pipeline := []bson.M{
 		{"$match": bson.M{"status": bson.M{"$in": []string{"Completed", "Paid"}}}},
-		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}},
+		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$amount.amount"}}},
 	}
 	cur, err := m.Payments.Aggregate(context.TODO(), pipeline)
 	if err != nil {
-		return 0, err
+		return money.Money{}, err
 	}
 	defer cur.Close(context.TODO())
-	var results []bson.M
-	if err = cur.All(context.TODO(), &results); err != nil || len(results) == 0 {
-		return 0, nil
+	var results []struct {
+		Total int64 `bson:"total"`
 	}
-	switch v := results[0]["total"].(type) {
-	case float64:
-		return v, nil
-	case int32:
-		return float64(v), nil
-	case int64:
-		return float64(v), nil
-	default:
-		return 0, nil
+	if err = cur.All(context.TODO(), &results); err != nil || len(results) == 0 {
+		return money.Money{}, nil
 	}
+	return money.FromMinor(results[0].Total), nil
 }
 
 func (m *MongoDB) GetTotalOrderCount() (int64, error) {
 	return m.Orders.CountDocuments(context.TODO(), bson.M{})
 }
 
-func (m *MongoDB) AddReview(r Review) error {
-	r.CreatedAt = time.Now()
-	_, err := m.Reviews.InsertOne(context.TODO(), r)
-	return err
-}
-
 func (m *MongoDB) GetReviews(pid primitive.ObjectID) ([]*Review, error) {
 	var reviews []*Review
-	cur, err := m.Reviews.Find(context.TODO(), bson.M{"productid": pid})
+	cur, err := m.Reviews.Find(context.TODO(), bson.M{"product_id": pid, "status": publicReview})
 	if err != nil {
 		return nil, err
 	}
@@ -168,23 +184,6 @@ func (m *MongoDB) DeleteUser(id primitive.ObjectID) error {
 	return err
 }
 
-func (m *MongoDB) AddCategory(name string) error {
-	cat := Category{ID: primitive.NewObjectID(), Name: name}
-	_, err := m.Categories.InsertOne(context.TODO(), cat)
-	return err
-}
-
-func (m *MongoDB) GetAllCategories() ([]*Category, error) {
-	var cats []*Category
-	cur, err := m.Categories.Find(context.TODO(), bson.M{})
-	if err != nil {
-		return nil, err
-	}
-	defer cur.Close(context.TODO())
-	err = cur.All(context.TODO(), &cats)
-	return cats, err
-}
-
 func (m *MongoDB) GetUniqueCities() ([]string, error) {
 	values, err := m.Products.Distinct(context.TODO(), "city", bson.M{})
 	if err != nil {
@@ -199,30 +198,37 @@ func (m *MongoDB) GetUniqueCities() ([]string, error) {
 	return cities, nil
 }
 
+// UpdateProduct saves the seller's edits. p.Price is the regular price: while
+// a sale is running it replaces the price the sale will restore.
 func (m *MongoDB) UpdateProduct(p Product) error {
-	filter := bson.M{"_id": p.ID}
-	update := bson.M{
-		"$set": bson.M{
-			"name":        p.Name,
-			"price":       p.Price,
-			"city":        p.City,
-			"description": p.Description,
-			"category_id": p.CategoryID,
-		},
+	current, err := m.GetProductByOID(p.ID)
+	if err != nil {
+		return err
 	}
-	_, err := m.Products.UpdateOne(context.TODO(), filter, update)
-	return err
-}
 
-func (m *MongoDB) ClearCart(userID primitive.ObjectID) error {
-	collection := m.Users.Database().Collection("cart")
-	_, err := collection.DeleteOne(context.TODO(), bson.M{"user_id": userID})
-	return err
+	fields := bson.M{
+		"name":        p.Name,
+		"city":        p.City,
+		"description": p.Description,
+		"category_id": p.CategoryID,
+		"attributes":  p.Attributes,
+	}
+	priceField := "price"
+	if current.OnSale() {
+		priceField = "original_price"
+	}
+	fields[priceField] = p.Price
+
+	_, err = m.Products.UpdateOne(context.TODO(), bson.M{"_id": p.ID}, bson.M{"$set": fields})
+	if err != nil || current.OnSale() || current.Price == p.Price {
+		return err
+	}
+	return m.RecordPriceChange(p.ID, p.Price, PriceChangeManual)
 }
 
 func (m *MongoDB) GetOrdersByUser(userID primitive.ObjectID) ([]*Order, error) {
 	var orders []*Order
-	cur, err := m.Orders.Find(context.TODO(), bson.M{"userid": userID})
+	cur, err := m.Orders.Find(context.TODO(), bson.M{"user_id": userID})
 	if err != nil {
 		return nil, err
 	}
@@ -231,25 +237,52 @@ func (m *MongoDB) GetOrdersByUser(userID primitive.ObjectID) ([]*Order, error) {
 	return orders, err
 }
 
-func (m *MongoDB) GetFilteredProducts(search, category, city string) ([]*Product, error) {
+// productFilter builds the catalog query shared by the product list and its
+// attribute facets. Nil categoryIDs means every category.
+func productFilter(search string, categoryIDs []primitive.ObjectID, city string, attrs map[string]string) bson.M {
 	filter := bson.M{}
 
 	if search != "" {
 		filter["name"] = bson.M{"$regex": search, "$options": "i"}
 	}
 
+	if categoryIDs != nil {
+		filter["category_id"] = bson.M{"$in": categoryIDs}
+	}
+
+	if city != "" {
+		filter["city"] = city
+	}
+
+	for k, v := range attrs {
+		if attributeKeyPattern.MatchString(k) && v != "" {
+			filter["attributes."+k] = v
+		}
+	}
+	return filter
+}
+
+func (m *MongoDB) GetFilteredProducts(search, category, city, sort string, attrs map[string]string) ([]*Product, error) {
+	var categoryIDs []primitive.ObjectID
 	if category != "" {
 		if oid, err := primitive.ObjectIDFromHex(category); err == nil {
-			filter["category_id"] = oid
+			categoryIDs, err = m.GetCategoryWithDescendants(oid)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
+	filter := productFilter(search, categoryIDs, city, attrs)
 
-	if city != "" {
-		filter["city"] = city
+	opts := options.Find()
+	if sort == "rating" {
+		opts.SetSort(bson.D{{Key: "rating_avg", Value: -1}, {Key: "rating_count", Value: -1}})
+	} else if sort == "popular" {
+		opts.SetSort(bson.D{{Key: "popularity", Value: -1}, {Key: "rating_avg", Value: -1}})
 	}
 
 	var products []*Product
-	cur, err := m.Products.Find(context.TODO(), filter)
+	cur, err := m.Products.Find(context.TODO(), filter, opts)
 	if err != nil {
 		return nil, err
 	}
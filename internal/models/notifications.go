@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Message   string             `bson:"message" json:"message"`
+	Link      string             `bson:"link,omitempty" json:"link,omitempty"`
+	Read      bool               `bson:"read" json:"read"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+func (m *MongoDB) Notify(n Notification) error {
+	n.ID = primitive.NewObjectID()
+	n.Read = false
+	n.CreatedAt = time.Now()
+	_, err := m.Notifications.InsertOne(context.TODO(), n)
+	return err
+}
+
+func (m *MongoDB) GetNotifications(userID primitive.ObjectID) ([]*Notification, error) {
+	var notifications []*Notification
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(100)
+	cur, err := m.Notifications.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &notifications)
+	return notifications, err
+}
+
+func (m *MongoDB) CountUnreadNotifications(userID primitive.ObjectID) (int64, error) {
+	return m.Notifications.CountDocuments(context.TODO(), bson.M{"user_id": userID, "read": false})
+}
+
+func (m *MongoDB) MarkNotificationsRead(userID primitive.ObjectID) error {
+	_, err := m.Notifications.UpdateMany(context.TODO(), bson.M{"user_id": userID, "read": false}, bson.M{"$set": bson.M{"read": true}})
+	return err
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The popularity score weighs what shoppers did with a product over the last
+// few weeks. A purchase says more than a view, and a day's activity counts
+// half as much a week later.
+const (
+	popularityWindow   = 28 * 24 * time.Hour
+	popularityHalfLife = 7 * 24 * time.Hour
+
+	viewWeight     = 1
+	cartWeight     = 5
+	purchaseWeight = 20
+)
+
+// UpdatePopularity recomputes the popularity of every product with recent
+// activity and clears it on the rest. It returns the number of scored
+// products.
+func (m *MongoDB) UpdatePopularity() (int, error) {
+	now := time.Now()
+	cur, err := m.ProductStats.Find(context.TODO(), bson.M{"day": bson.M{"$gte": Day(now.Add(-popularityWindow))}})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.TODO())
+
+	var days []ProductDay
+	if err := cur.All(context.TODO(), &days); err != nil {
+		return 0, err
+	}
+
+	scores := map[primitive.ObjectID]float64{}
+	for _, d := range days {
+		age := now.Sub(d.Day)
+		decay := math.Pow(0.5, float64(age)/float64(popularityHalfLife))
+		scores[d.ProductID] += decay * float64(d.Views*viewWeight+d.Carts*cartWeight+d.Purchases*purchaseWeight)
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(scores))
+	for id, score := range scores {
+		ids = append(ids, id)
+		score = math.Round(score*100) / 100
+		_, err := m.Products.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"popularity": score}})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = m.Products.UpdateMany(context.TODO(),
+		bson.M{"_id": bson.M{"$nin": ids}, "popularity": bson.M{"$gt": 0}},
+		bson.M{"$set": bson.M{"popularity": 0}})
+	return len(ids), err
+}
+
+// GetTrendingProducts returns the most popular products, limited to a city
+// unless it is empty.
+func (m *MongoDB) GetTrendingProducts(city string, limit int64) ([]*Product, error) {
+	filter := bson.M{"popularity": bson.M{"$gt": 0}}
+	if city != "" {
+		filter["city"] = city
+	}
+
+	var products []*Product
+	opts := options.Find().SetSort(bson.D{{Key: "popularity", Value: -1}}).SetLimit(limit)
+	cur, err := m.Products.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &products)
+	return products, err
+}
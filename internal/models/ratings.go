@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (p *Product) RatingShare(stars int) int {
+	if p.RatingCount == 0 || stars < MinRating || stars > MaxRating {
+		return 0
+	}
+	return p.RatingCounts[stars-1] * 100 / p.RatingCount
+}
+
+// withTransaction runs fn in a transaction, so that a review change and the
+// rating summary of its product are written together. A standalone server
+// has no transactions; there fn runs without one, and a summary left stale by
+// a crash between the writes is repaired by -recompute-ratings.
+func (m *MongoDB) withTransaction(fn func(ctx mongo.SessionContext) error) error {
+	sess, err := m.Products.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(context.TODO())
+
+	_, err = sess.WithTransaction(context.TODO(), func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	if !transactionsUnsupported(err) {
+		return err
+	}
+	return mongo.WithSession(context.TODO(), sess, fn)
+}
+
+// transactionsUnsupported reports the IllegalOperation error with which a
+// standalone server rejects the first operation of a transaction.
+func transactionsUnsupported(err error) bool {
+	var ce mongo.CommandError
+	return errors.As(err, &ce) && ce.Code == 20
+}
+
+// recomputeRating rebuilds the rating summary stored on the product from its reviews.
+func (m *MongoDB) recomputeRating(ctx context.Context, productID primitive.ObjectID) error {
+	pipeline := []bson.M{
+		{"$match": bson.M{"product_id": productID, "status": publicReview}},
+		{"$group": bson.M{"_id": "$rating", "count": bson.M{"$sum": 1}}},
+	}
+	cur, err := m.Reviews.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var rows []struct {
+		Rating int `bson:"_id"`
+		Count  int `bson:"count"`
+	}
+	if err = cur.All(ctx, &rows); err != nil {
+		return err
+	}
+
+	var counts [MaxRating]int
+	var total, sum int
+	for _, row := range rows {
+		if row.Rating < MinRating || row.Rating > MaxRating {
+			continue
+		}
+		counts[row.Rating-1] = row.Count
+		total += row.Count
+		sum += row.Rating * row.Count
+	}
+
+	var avg float64
+	if total > 0 {
+		avg = float64(sum) / float64(total)
+	}
+
+	_, err = m.Products.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{"$set": bson.M{
+		"rating_avg":    avg,
+		"rating_count":  total,
+		"rating_counts": counts,
+	}})
+	return err
+}
+
+// RecomputeAllRatings refreshes the rating summary of every product, e.g.
+// after reviews were changed directly in the database.
+func (m *MongoDB) RecomputeAllRatings() (int, error) {
+	ids, err := m.Products.Distinct(context.TODO(), "_id", bson.M{})
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, v := range ids {
+		id, ok := v.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+		if err := m.recomputeRating(context.TODO(), id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
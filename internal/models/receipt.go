@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrNotPaid = errors.New("order has not been paid")
+
+// Invoice is an order with its buyer, payment and lines grouped by the
+// seller who sold them. Once the order is paid it doubles as the receipt.
+type Invoice struct {
+	Order   *Order
+	Buyer   *User
+	Payment *Payment
+	Sellers []*SellerLines
+}
+
+type SellerLines struct {
+	Seller *User
+	Items  []OrderItem
+	Total  money.Money
+	Tax    money.Money
+}
+
+// Lines returns the group of the given seller, or nil if the order has
+// nothing from them.
+func (inv *Invoice) Lines(sellerID primitive.ObjectID) *SellerLines {
+	for _, g := range inv.Sellers {
+		if g.Seller.ID == sellerID {
+			return g
+		}
+	}
+	return nil
+}
+
+// GetInvoice builds the invoice of an order. Payment is nil while the order
+// is unpaid.
+func (m *MongoDB) GetInvoice(orderID primitive.ObjectID) (*Invoice, error) {
+	order, err := m.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	payment, err := m.GetPaymentByOrder(orderID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		payment = nil
+	} else if err != nil {
+		return nil, err
+	}
+	buyer, err := m.GetUser(order.UserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		buyer = &User{ID: order.UserID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Orders placed before taxes were recorded have no seller on their lines.
+	var missing []primitive.ObjectID
+	for _, item := range order.Items {
+		if item.SellerID.IsZero() {
+			missing = append(missing, item.ProductID)
+		}
+	}
+	if len(missing) > 0 {
+		products, err := m.productsByID(missing)
+		if err != nil {
+			return nil, err
+		}
+		for i := range order.Items {
+			if p, ok := products[order.Items[i].ProductID]; ok && order.Items[i].SellerID.IsZero() {
+				order.Items[i].SellerID = p.SellerID
+			}
+		}
+	}
+
+	sellerIDs := make([]primitive.ObjectID, 0, len(order.Items))
+	for _, item := range order.Items {
+		sellerIDs = append(sellerIDs, item.SellerID)
+	}
+	sellers, err := m.usersByID(sellerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &Invoice{Order: order, Buyer: buyer, Payment: payment}
+	groups := map[primitive.ObjectID]*SellerLines{}
+	for _, item := range order.Items {
+		g, ok := groups[item.SellerID]
+		if !ok {
+			g = &SellerLines{Seller: sellers[item.SellerID]}
+			if g.Seller == nil {
+				g.Seller = &User{ID: item.SellerID}
+			}
+			groups[item.SellerID] = g
+			inv.Sellers = append(inv.Sellers, g)
+		}
+		g.Items = append(g.Items, item)
+		g.Total = g.Total.Add(item.Total().Sub(item.Discount))
+		g.Tax = g.Tax.Add(item.Tax)
+	}
+	if !order.TaxInclusive {
+		for _, g := range inv.Sellers {
+			g.Total = g.Total.Add(g.Tax)
+		}
+	}
+	return inv, nil
+}
+
+// GetReceipt returns the invoice of a paid order, or ErrNotPaid.
+func (m *MongoDB) GetReceipt(orderID primitive.ObjectID) (*Invoice, error) {
+	inv, err := m.GetInvoice(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Payment == nil || inv.Payment.Status != "Paid" {
+		return nil, ErrNotPaid
+	}
+	return inv, nil
+}
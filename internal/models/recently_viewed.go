@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RecentlyViewedLimit bounds the browsing history kept for a user or guest.
+const RecentlyViewedLimit = 20
+
+// RecentlyViewed is a user's browsing history, most recent product first.
+type RecentlyViewed struct {
+	UserID     primitive.ObjectID   `bson:"_id"`
+	ProductIDs []primitive.ObjectID `bson:"product_ids"`
+	UpdatedAt  time.Time            `bson:"updated_at"`
+}
+
+// AddRecentlyViewed moves the products to the front of the user's history,
+// in the given order, and drops the oldest ones beyond the limit.
+func (m *MongoDB) AddRecentlyViewed(userID primitive.ObjectID, productIDs ...primitive.ObjectID) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	older := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$product_ids", bson.A{}}},
+		"cond":  bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$this", productIDs}}}},
+	}}
+	update := bson.A{bson.M{"$set": bson.M{
+		"product_ids": bson.M{"$slice": bson.A{bson.M{"$concatArrays": bson.A{productIDs, older}}, RecentlyViewedLimit}},
+		"updated_at":  time.Now(),
+	}}}
+	_, err := m.RecentlyViewed.UpdateOne(context.TODO(), bson.M{"_id": userID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (m *MongoDB) GetRecentlyViewedIDs(userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var rv RecentlyViewed
+	err := m.RecentlyViewed.FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&rv)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return rv.ProductIDs, err
+}
+
+func (m *MongoDB) ClearRecentlyViewed(userID primitive.ObjectID) error {
+	_, err := m.RecentlyViewed.DeleteOne(context.TODO(), bson.M{"_id": userID})
+	return err
+}
+
+// GetProductsByIDs returns the products in the order of ids, skipping the
+// ones that no longer exist.
+func (m *MongoDB) GetProductsByIDs(ids []primitive.ObjectID) ([]*Product, error) {
+	byID, err := m.productsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+	products := make([]*Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
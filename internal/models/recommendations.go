@@ -0,0 +1,222 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recommendationLimit is how many products are kept per list.
+const recommendationLimit = 12
+
+// recommendationBatch is how many lists are written in one bulk request.
+const recommendationBatch = 1000
+
+// Recommendation holds the precomputed lists of a product. AlsoBought comes
+// from orders containing the product, Similar from products of the same
+// category, preferring the same city.
+type Recommendation struct {
+	ProductID  primitive.ObjectID   `bson:"_id"`
+	AlsoBought []primitive.ObjectID `bson:"also_bought"`
+	Similar    []primitive.ObjectID `bson:"similar"`
+	UpdatedAt  time.Time            `bson:"updated_at"`
+}
+
+// RebuildRecommendations recomputes the lists of all products. It returns
+// the number of products with recommendations.
+func (m *MongoDB) RebuildRecommendations() (int, error) {
+	alsoBought, err := m.coPurchases()
+	if err != nil {
+		return 0, err
+	}
+
+	products, err := m.GetAllProducts()
+	if err != nil {
+		return 0, err
+	}
+	similar := similarProducts(products)
+
+	now := time.Now()
+	var writes []mongo.WriteModel
+	for _, p := range products {
+		rec := Recommendation{ProductID: p.ID, AlsoBought: alsoBought[p.ID], Similar: similar[p.ID], UpdatedAt: now}
+		if len(rec.AlsoBought) == 0 && len(rec.Similar) == 0 {
+			continue
+		}
+		writes = append(writes, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": p.ID}).SetReplacement(rec).SetUpsert(true))
+	}
+	for start := 0; start < len(writes); start += recommendationBatch {
+		end := min(start+recommendationBatch, len(writes))
+		if _, err := m.Recommendations.BulkWrite(context.TODO(), writes[start:end], options.BulkWrite().SetOrdered(false)); err != nil {
+			return 0, err
+		}
+	}
+
+	// Every list written above carries now, so older ones are stale.
+	_, err = m.Recommendations.DeleteMany(context.TODO(), bson.M{"updated_at": bson.M{"$lt": now}})
+	return len(writes), err
+}
+
+// coPurchases counts how often two products were bought in the same order
+// and lists each product's partners, most frequent first.
+func (m *MongoDB) coPurchases() (map[primitive.ObjectID][]primitive.ObjectID, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"status": bson.M{"$nin": bson.A{"Pending", "Cancelled"}}}},
+		bson.M{"$project": bson.M{"a": bson.M{"$setUnion": bson.A{"$items.product_id", bson.A{}}}}},
+		bson.M{"$match": bson.M{"a.1": bson.M{"$exists": true}}},
+		bson.M{"$set": bson.M{"b": "$a"}},
+		bson.M{"$unwind": "$a"},
+		bson.M{"$unwind": "$b"},
+		bson.M{"$match": bson.M{"$expr": bson.M{"$ne": bson.A{"$a", "$b"}}}},
+		bson.M{"$group": bson.M{"_id": bson.M{"a": "$a", "b": "$b"}, "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "_id.a", Value: 1}, {Key: "count", Value: -1}, {Key: "_id.b", Value: 1}}},
+		bson.M{"$group": bson.M{"_id": "$_id.a", "partners": bson.M{"$push": "$_id.b"}}},
+		bson.M{"$project": bson.M{"partners": bson.M{"$slice": bson.A{"$partners", recommendationLimit}}}},
+	}
+	// The pair grouping outgrows the in-memory limit on a large order history.
+	cur, err := m.Orders.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var rows []struct {
+		ID       primitive.ObjectID   `bson:"_id"`
+		Partners []primitive.ObjectID `bson:"partners"`
+	}
+	if err := cur.All(context.TODO(), &rows); err != nil {
+		return nil, err
+	}
+	pairs := make(map[primitive.ObjectID][]primitive.ObjectID, len(rows))
+	for _, r := range rows {
+		pairs[r.ID] = r.Partners
+	}
+	return pairs, nil
+}
+
+// similarProducts ranks the other products of each category: same city
+// first, then by popularity and rating.
+func similarProducts(products []*Product) map[primitive.ObjectID][]primitive.ObjectID {
+	byCategory := map[primitive.ObjectID][]*Product{}
+	for _, p := range products {
+		if !p.CategoryID.IsZero() {
+			byCategory[p.CategoryID] = append(byCategory[p.CategoryID], p)
+		}
+	}
+
+	similar := make(map[primitive.ObjectID][]primitive.ObjectID, len(products))
+	for _, group := range byCategory {
+		for _, p := range group {
+			others := make([]*Product, 0, len(group)-1)
+			for _, o := range group {
+				if o.ID != p.ID {
+					others = append(others, o)
+				}
+			}
+			sort.SliceStable(others, func(i, j int) bool {
+				a, b := others[i], others[j]
+				if (a.City == p.City) != (b.City == p.City) {
+					return a.City == p.City
+				}
+				if a.Popularity != b.Popularity {
+					return a.Popularity > b.Popularity
+				}
+				return a.RatingAvg > b.RatingAvg
+			})
+			if len(others) > recommendationLimit {
+				others = others[:recommendationLimit]
+			}
+			for _, o := range others {
+				similar[p.ID] = append(similar[p.ID], o.ID)
+			}
+		}
+	}
+	return similar
+}
+
+// GetRecommendations returns the products bought together with the product
+// and the similar ones, leaving out what is out of stock.
+func (m *MongoDB) GetRecommendations(productID primitive.ObjectID, limit int) (alsoBought, similar []*Product, err error) {
+	var rec Recommendation
+	err = m.Recommendations.FindOne(context.TODO(), bson.M{"_id": productID}).Decode(&rec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID, err := m.productsByID(append(rec.AlsoBought, rec.Similar...))
+	if err != nil {
+		return nil, nil, err
+	}
+	alsoBought = pickProducts(byID, rec.AlsoBought, nil, limit)
+	shown := map[primitive.ObjectID]bool{productID: true}
+	for _, p := range alsoBought {
+		shown[p.ID] = true
+	}
+	similar = pickProducts(byID, rec.Similar, shown, limit)
+	return alsoBought, similar, nil
+}
+
+// GetCartRecommendations suggests products for a cart from the lists of the
+// products already in it.
+func (m *MongoDB) GetCartRecommendations(productIDs []primitive.ObjectID, limit int) ([]*Product, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	var recs []Recommendation
+	cur, err := m.Recommendations.Find(context.TODO(), bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err := cur.All(context.TODO(), &recs); err != nil {
+		return nil, err
+	}
+
+	// Co-purchases of all cart items come before any similar product.
+	var candidates []primitive.ObjectID
+	for _, rec := range recs {
+		candidates = append(candidates, rec.AlsoBought...)
+	}
+	for _, rec := range recs {
+		candidates = append(candidates, rec.Similar...)
+	}
+
+	byID, err := m.productsByID(candidates)
+	if err != nil {
+		return nil, err
+	}
+	inCart := make(map[primitive.ObjectID]bool, len(productIDs))
+	for _, id := range productIDs {
+		inCart[id] = true
+	}
+	return pickProducts(byID, candidates, inCart, limit), nil
+}
+
+// pickProducts returns up to limit products in the order of ids, skipping
+// missing, out of stock and excluded ones.
+func pickProducts(byID map[primitive.ObjectID]*Product, ids []primitive.ObjectID, exclude map[primitive.ObjectID]bool, limit int) []*Product {
+	var picked []*Product
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		p, ok := byID[id]
+		if !ok || p.Stock <= 0 || exclude[id] || seen[id] {
+			continue
+		}
+		seen[id] = true
+		picked = append(picked, p)
+		if len(picked) == limit {
+			break
+		}
+	}
+	return picked
+}
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+	ErrReturnNotAllowed    = errors.New("return is not allowed for this item")
+	ErrReturnResolved      = errors.New("return request is already resolved")
+)
+
+// Orders can be cancelled by the customer only until they leave the warehouse.
+var cancellableStatuses = []string{"Pending", "Paid", "Processing"}
+
+type ReturnRequest struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID    primitive.ObjectID `bson:"order_id" json:"order_id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ProductID  primitive.ObjectID `bson:"product_id" json:"product_id"`
+	SellerID   primitive.ObjectID `bson:"seller_id" json:"seller_id"`
+	Name       string             `bson:"name" json:"name"`
+	Quantity   int                `bson:"quantity" json:"quantity"`
+	Amount     money.Money        `bson:"amount" json:"amount"`
+	Reason     string             `bson:"reason" json:"reason"`
+	Photos     []string           `bson:"photos" json:"photos"`
+	Status     string             `bson:"status" json:"status"`
+	RefundID   primitive.ObjectID `bson:"refund_id,omitempty" json:"refund_id,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ResolvedAt time.Time          `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
+
+func (o *Order) Cancellable() bool {
+	for _, s := range cancellableStatuses {
+		if o.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MongoDB) CreatePayment(p Payment) (primitive.ObjectID, error) {
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+	p.CreatedAt = time.Now()
+	_, err := m.Payments.InsertOne(context.TODO(), p)
+	return p.ID, err
+}
+
+func (m *MongoDB) GetPaymentByOrder(orderID primitive.ObjectID) (*Payment, error) {
+	var p Payment
+	filter := bson.M{"order_id": orderID, "refund_of": bson.M{"$exists": false}}
+	err := m.Payments.FindOne(context.TODO(), filter).Decode(&p)
+	return &p, err
+}
+
+func (m *MongoDB) restock(items []OrderItem) {
+	for _, item := range items {
+		m.Products.UpdateOne(context.TODO(), bson.M{"_id": item.ProductID}, bson.M{"$inc": bson.M{"stock": item.Quantity}})
+	}
+}
+
+// refund records a refund payment linked to the original payment of the order.
+// Orders that were never paid have nothing to refund.
+func (m *MongoDB) refund(orderID primitive.ObjectID, amount money.Money) (primitive.ObjectID, error) {
+	original, err := m.GetPaymentByOrder(orderID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, nil
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return m.CreatePayment(Payment{
+		OrderID:  orderID,
+		Amount:   amount,
+		Status:   "Refunded",
+		Method:   original.Method,
+		RefundOf: original.ID,
+	})
+}
+
+func (m *MongoDB) CancelOrder(orderID primitive.ObjectID) error {
+	var o Order
+	filter := bson.M{"_id": orderID, "status": bson.M{"$in": cancellableStatuses}}
+	update := bson.M{"$set": bson.M{"status": "Cancelled"}}
+	err := m.Orders.FindOneAndUpdate(context.TODO(), filter, update).Decode(&o)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrOrderNotCancellable
+	}
+	if err != nil {
+		return err
+	}
+
+	m.restock(o.Items)
+
+	if err := m.ReleaseCoupon(o.Discount); err != nil {
+		return err
+	}
+
+	_, err = m.refund(o.ID, o.TotalPrice)
+	return err
+}
+
+func (m *MongoDB) CreateReturnRequest(rr ReturnRequest) error {
+	order, err := m.GetOrder(rr.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.UserID != rr.UserID || order.Status != "Delivered" {
+		return ErrReturnNotAllowed
+	}
+
+	var item *OrderItem
+	for i := range order.Items {
+		if order.Items[i].ProductID == rr.ProductID {
+			item = &order.Items[i]
+			break
+		}
+	}
+	if item == nil || rr.Quantity <= 0 || rr.Quantity > item.Quantity {
+		return ErrReturnNotAllowed
+	}
+
+	existing, err := m.Returns.CountDocuments(context.TODO(), bson.M{
+		"order_id":   rr.OrderID,
+		"product_id": rr.ProductID,
+		"status":     bson.M{"$ne": "Rejected"},
+	})
+	if err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrReturnNotAllowed
+	}
+
+	if p, err := m.GetProductByOID(rr.ProductID); err == nil {
+		rr.SellerID = p.SellerID
+		rr.Name = p.Name
+	}
+
+	rr.ID = primitive.NewObjectID()
+	rr.Amount = item.PaidFor(rr.Quantity, order.TaxInclusive)
+	rr.Status = "Requested"
+	rr.CreatedAt = time.Now()
+	_, err = m.Returns.InsertOne(context.TODO(), rr)
+	return err
+}
+
+func (m *MongoDB) GetReturnRequest(id primitive.ObjectID) (*ReturnRequest, error) {
+	var rr ReturnRequest
+	err := m.Returns.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&rr)
+	return &rr, err
+}
+
+func (m *MongoDB) findReturns(filter bson.M) ([]*ReturnRequest, error) {
+	var returns []*ReturnRequest
+	cur, err := m.Returns.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &returns)
+	return returns, err
+}
+
+func (m *MongoDB) GetReturnsByOrder(orderID primitive.ObjectID) ([]*ReturnRequest, error) {
+	return m.findReturns(bson.M{"order_id": orderID})
+}
+
+func (m *MongoDB) GetReturnsBySeller(sellerID primitive.ObjectID) ([]*ReturnRequest, error) {
+	return m.findReturns(bson.M{"seller_id": sellerID})
+}
+
+func (m *MongoDB) GetAllReturns() ([]*ReturnRequest, error) {
+	return m.findReturns(bson.M{})
+}
+
+// ResolveReturn approves or rejects a pending return. Approved returns are
+// refunded against the original payment and the items go back into stock.
+func (m *MongoDB) ResolveReturn(id primitive.ObjectID, approve bool) error {
+	status := "Rejected"
+	if approve {
+		status = "Approved"
+	}
+
+	var rr ReturnRequest
+	filter := bson.M{"_id": id, "status": "Requested"}
+	update := bson.M{"$set": bson.M{"status": status, "resolved_at": time.Now()}}
+	err := m.Returns.FindOneAndUpdate(context.TODO(), filter, update).Decode(&rr)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrReturnResolved
+	}
+	if err != nil || !approve {
+		return err
+	}
+
+	m.restock([]OrderItem{{ProductID: rr.ProductID, Quantity: rr.Quantity}})
+
+	refundID, err := m.refund(rr.OrderID, rr.Amount)
+	if err != nil || refundID.IsZero() {
+		return err
+	}
+	_, err = m.Returns.UpdateOne(context.TODO(), bson.M{"_id": rr.ID}, bson.M{"$set": bson.M{"refund_id": refundID}})
+	return err
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+const (
+	ReviewPublished = "Published"
+	ReviewPending   = "Pending"
+	ReviewHidden    = "Hidden"
+)
+
+var (
+	ErrInvalidRating    = errors.New("rating must be between 1 and 5")
+	ErrReviewNotAllowed = errors.New("only customers with a delivered order can review this product")
+)
+
+// Reviews written before moderation existed have no status and stay visible.
+var publicReview = bson.M{"$nin": []string{ReviewPending, ReviewHidden}}
+
+// HasPurchased reports whether the user has a delivered order containing the product.
+func (m *MongoDB) HasPurchased(userID, productID primitive.ObjectID) (bool, error) {
+	n, err := m.Orders.CountDocuments(context.TODO(), bson.M{
+		"user_id":          userID,
+		"status":           "Delivered",
+		"items.product_id": productID,
+	})
+	return n > 0, err
+}
+
+func (m *MongoDB) GetUserReview(userID, productID primitive.ObjectID) (*Review, error) {
+	var r Review
+	err := m.Reviews.FindOne(context.TODO(), bson.M{"user_id": userID, "product_id": productID}).Decode(&r)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// SaveReview creates the user's review of a product or edits the existing one,
+// so every customer has at most one review per product.
+func (m *MongoDB) SaveReview(r Review) error {
+	if r.Rating < MinRating || r.Rating > MaxRating {
+		return ErrInvalidRating
+	}
+
+	ok, err := m.HasPurchased(r.UserID, r.ProductID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReviewNotAllowed
+	}
+
+	status := ReviewPublished
+	if len(r.Flags) > 0 {
+		status = ReviewPending
+	} else if existing, err := m.GetUserReview(r.UserID, r.ProductID); err != nil {
+		return err
+	} else if existing != nil && existing.Status == ReviewHidden {
+		status = ReviewPending
+	}
+
+	now := time.Now()
+	filter := bson.M{"user_id": r.UserID, "product_id": r.ProductID}
+	update := bson.M{
+		"$set": bson.M{
+			"rating":    r.Rating,
+			"comment":   r.Comment,
+			"verified":  true,
+			"status":    status,
+			"flags":     r.Flags,
+			"updatedat": now,
+		},
+		"$setOnInsert": bson.M{"createdat": now},
+	}
+	return m.withTransaction(func(ctx mongo.SessionContext) error {
+		_, err := m.Reviews.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+		return m.recomputeRating(ctx, r.ProductID)
+	})
+}
+
+func (m *MongoDB) DeleteReview(id primitive.ObjectID) error {
+	return m.withTransaction(func(ctx mongo.SessionContext) error {
+		var r Review
+		err := m.Reviews.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&r)
+		if err != nil {
+			return err
+		}
+		return m.recomputeRating(ctx, r.ProductID)
+	})
+}
+
+func (m *MongoDB) GetReview(id primitive.ObjectID) (*Review, error) {
+	var r Review
+	err := m.Reviews.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&r)
+	return &r, err
+}
+
+// SetReviewReply stores the seller's public answer to a review. An empty text
+// removes the answer.
+func (m *MongoDB) SetReviewReply(id primitive.ObjectID, text string) error {
+	if text == "" {
+		_, err := m.Reviews.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$unset": bson.M{"reply": ""}})
+		return err
+	}
+
+	now := time.Now()
+	_, err := m.Reviews.UpdateOne(context.TODO(), bson.M{"_id": id}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"reply": bson.M{
+			"text":      text,
+			"createdat": bson.M{"$ifNull": bson.A{"$reply.createdat", now}},
+			"updatedat": now,
+		}}}},
+	})
+	return err
+}
+
+func (m *MongoDB) GetReviewsAwaitingReply(productIDs []primitive.ObjectID) ([]*Review, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+	filter := bson.M{
+		"product_id": bson.M{"$in": productIDs},
+		"status":     publicReview,
+		"reply":      nil,
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}})
+
+	var reviews []*Review
+	cur, err := m.Reviews.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &reviews)
+	return reviews, err
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidSale = errors.New("invalid sale")
+	ErrSaleActive  = errors.New("product already has a running sale")
+)
+
+const (
+	PriceChangeCreated   = "created"
+	PriceChangeManual    = "manual"
+	PriceChangeSaleStart = "sale_start"
+	PriceChangeSaleEnd   = "sale_end"
+)
+
+// Sale is a price reduction scheduled by the seller. While the sale is
+// running the product's Price holds the sale price and OriginalPrice keeps
+// the regular one, so carts, coupons and sorting keep working off Price.
+type Sale struct {
+	Price    money.Money `bson:"price" json:"price"`
+	StartsAt time.Time   `bson:"starts_at" json:"starts_at"`
+	EndsAt   time.Time   `bson:"ends_at" json:"ends_at"`
+	Active   bool        `bson:"active" json:"active"`
+}
+
+// PriceChange is an entry in a product's price history. Price is the price
+// customers saw after the change.
+type PriceChange struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Price     money.Money        `bson:"price" json:"price"`
+	Reason    string             `bson:"reason" json:"reason"`
+	ChangedAt time.Time          `bson:"changed_at" json:"changed_at"`
+}
+
+func (p *Product) OnSale() bool {
+	return p.Sale != nil && p.Sale.Active
+}
+
+// RegularPrice is the price without a running sale.
+func (p *Product) RegularPrice() money.Money {
+	if p.OnSale() {
+		return p.OriginalPrice
+	}
+	return p.Price
+}
+
+// SalePercent is the sale discount rounded to whole percent, for badges.
+func (p *Product) SalePercent() int {
+	if !p.OnSale() || !p.OriginalPrice.IsPositive() {
+		return 0
+	}
+	off := p.OriginalPrice.Sub(p.Price).Amount
+	return int((off*100 + p.OriginalPrice.Amount/2) / p.OriginalPrice.Amount)
+}
+
+func (m *MongoDB) RecordPriceChange(productID primitive.ObjectID, price money.Money, reason string) error {
+	_, err := m.PriceHistory.InsertOne(context.TODO(), PriceChange{
+		ID:        primitive.NewObjectID(),
+		ProductID: productID,
+		Price:     price,
+		Reason:    reason,
+		ChangedAt: time.Now(),
+	})
+	return err
+}
+
+func (m *MongoDB) GetPriceHistory(productID primitive.ObjectID) ([]*PriceChange, error) {
+	var history []*PriceChange
+	opts := options.Find().SetSort(bson.M{"changed_at": -1})
+	cur, err := m.PriceHistory.Find(context.TODO(), bson.M{"product_id": productID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &history)
+	return history, err
+}
+
+// ScheduleSale sets up a sale for the product. A sale whose start time has
+// already passed begins right away.
+func (m *MongoDB) ScheduleSale(productID primitive.ObjectID, sale Sale) error {
+	p, err := m.GetProductByOID(productID)
+	if err != nil {
+		return err
+	}
+	if p.OnSale() {
+		return ErrSaleActive
+	}
+	if !sale.Price.IsPositive() || !sale.Price.Less(p.Price) || !sale.EndsAt.After(sale.StartsAt) || !sale.EndsAt.After(time.Now()) {
+		return ErrInvalidSale
+	}
+
+	sale.Active = false
+	_, err = m.Products.UpdateOne(context.TODO(), bson.M{"_id": productID}, bson.M{"$set": bson.M{"sale": sale}})
+	if err != nil {
+		return err
+	}
+	_, _, err = m.UpdateSales()
+	return err
+}
+
+// CancelSale drops the product's sale, restoring the regular price if the
+// sale is already running.
+func (m *MongoDB) CancelSale(productID primitive.ObjectID) error {
+	p, err := m.GetProductByOID(productID)
+	if err != nil || p.Sale == nil {
+		return err
+	}
+	return m.endSale(p)
+}
+
+func (m *MongoDB) endSale(p *Product) error {
+	update := bson.M{"$unset": bson.M{"sale": "", "original_price": ""}}
+	if p.OnSale() {
+		update["$set"] = bson.M{"price": p.OriginalPrice}
+	}
+	_, err := m.Products.UpdateOne(context.TODO(), bson.M{"_id": p.ID}, update)
+	if err != nil || !p.OnSale() {
+		return err
+	}
+	return m.RecordPriceChange(p.ID, p.OriginalPrice, PriceChangeSaleEnd)
+}
+
+func (m *MongoDB) findProducts(filter bson.M) ([]*Product, error) {
+	var products []*Product
+	cur, err := m.Products.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &products)
+	return products, err
+}
+
+// UpdateSales starts sales whose time has come and ends the ones that are
+// over. It returns how many sales were started and ended.
+func (m *MongoDB) UpdateSales() (started, ended int, err error) {
+	now := time.Now()
+
+	expired, err := m.findProducts(bson.M{"sale.ends_at": bson.M{"$lte": now}})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, p := range expired {
+		if err := m.endSale(p); err != nil {
+			return started, ended, err
+		}
+		ended++
+	}
+
+	due, err := m.findProducts(bson.M{
+		"sale.active":    false,
+		"sale.starts_at": bson.M{"$lte": now},
+		"sale.ends_at":   bson.M{"$gt": now},
+	})
+	if err != nil {
+		return started, ended, err
+	}
+	for _, p := range due {
+		filter := bson.M{"_id": p.ID, "sale.active": false}
+		update := bson.M{"$set": bson.M{
+			"original_price": p.Price,
+			"price":          p.Sale.Price,
+			"sale.active":    true,
+		}}
+		res, err := m.Products.UpdateOne(context.TODO(), filter, update)
+		if err != nil {
+			return started, ended, err
+		}
+		if res.ModifiedCount == 0 {
+			continue
+		}
+		if err := m.RecordPriceChange(p.ID, p.Sale.Price, PriceChangeSaleStart); err != nil {
+			return started, ended, err
+		}
+		started++
+	}
+	return started, ended, nil
+}
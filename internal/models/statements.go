@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Statement is a seller's monthly account of sold lines and refunds.
+type Statement struct {
+	Seller    *User
+	From      time.Time
+	To        time.Time
+	Lines     []StatementLine
+	Refunds   []*ReturnRequest
+	Sales     money.Money
+	Discounts money.Money
+	Tax       money.Money
+	Refunded  money.Money
+	Net       money.Money
+}
+
+type StatementLine struct {
+	OrderID  primitive.ObjectID
+	Date     time.Time
+	Name     string
+	Quantity int
+	Amount   money.Money
+	Discount money.Money
+	Tax      money.Money
+}
+
+// sellerOrdersFilter matches orders with lines of the seller. Older orders
+// have no seller on their lines, so they are matched by the seller's
+// products instead.
+func (m *MongoDB) sellerOrdersFilter(sellerID primitive.ObjectID) (bson.M, map[primitive.ObjectID]bool, error) {
+	products, err := m.findProducts(bson.M{"seller_id": sellerID})
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := make(map[primitive.ObjectID]bool, len(products))
+	ids := make([]primitive.ObjectID, 0, len(products))
+	for _, p := range products {
+		owned[p.ID] = true
+		ids = append(ids, p.ID)
+	}
+
+	filter := bson.M{
+		"status": bson.M{"$nin": bson.A{"Pending", "Cancelled"}},
+		"$or": bson.A{
+			bson.M{"items.seller_id": sellerID},
+			bson.M{"items.product_id": bson.M{"$in": ids}},
+		},
+	}
+	return filter, owned, nil
+}
+
+func sellsLine(item OrderItem, sellerID primitive.ObjectID, owned map[primitive.ObjectID]bool) bool {
+	if !item.SellerID.IsZero() {
+		return item.SellerID == sellerID
+	}
+	return owned[item.ProductID]
+}
+
+// GetSellerOrders lists the latest paid orders with lines of the seller.
+func (m *MongoDB) GetSellerOrders(sellerID primitive.ObjectID, limit int64) ([]*Order, error) {
+	filter, _, err := m.sellerOrdersFilter(sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []*Order
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit)
+	cur, err := m.Orders.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	err = cur.All(context.TODO(), &orders)
+	return orders, err
+}
+
+// GetSellerStatement collects the seller's sales in orders placed during the
+// month containing the given time, and the refunds approved in that month.
+func (m *MongoDB) GetSellerStatement(sellerID primitive.ObjectID, month time.Time) (*Statement, error) {
+	seller, err := m.GetUser(sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	st := &Statement{Seller: seller, From: from, To: from.AddDate(0, 1, 0)}
+
+	filter, owned, err := m.sellerOrdersFilter(sellerID)
+	if err != nil {
+		return nil, err
+	}
+	filter["created_at"] = bson.M{"$gte": st.From, "$lt": st.To}
+
+	var orders []*Order
+	cur, err := m.Orders.Find(context.TODO(), filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &orders); err != nil {
+		return nil, err
+	}
+
+	for _, o := range orders {
+		for _, item := range o.Items {
+			if !sellsLine(item, sellerID, owned) {
+				continue
+			}
+			st.Lines = append(st.Lines, StatementLine{
+				OrderID:  o.ID,
+				Date:     o.CreatedAt,
+				Name:     item.Name,
+				Quantity: item.Quantity,
+				Amount:   item.Total(),
+				Discount: item.Discount,
+				Tax:      item.Tax,
+			})
+			st.Sales = st.Sales.Add(item.Total())
+			st.Discounts = st.Discounts.Add(item.Discount)
+			st.Tax = st.Tax.Add(item.Tax)
+			st.Net = st.Net.Add(item.Total().Sub(item.Discount))
+			if !o.TaxInclusive {
+				st.Net = st.Net.Add(item.Tax)
+			}
+		}
+	}
+
+	st.Refunds, err = m.findReturns(bson.M{
+		"seller_id":   sellerID,
+		"status":      "Approved",
+		"resolved_at": bson.M{"$gte": st.From, "$lt": st.To},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range st.Refunds {
+		st.Refunded = st.Refunded.Add(rr.Amount)
+	}
+	st.Net = st.Net.Sub(st.Refunded)
+	return st, nil
+}
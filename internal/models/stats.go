@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Location is Kazakhstan time, used to cut statistics into days.
+var Location = time.FixedZone("UTC+5", 5*60*60)
+
+// Day returns the start of the day containing t.
+func Day(t time.Time) time.Time {
+	t = t.In(Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Location)
+}
+
+// ProductDay counts what happened to a product during one day.
+type ProductDay struct {
+	ProductID primitive.ObjectID `bson:"product_id"`
+	Day       time.Time          `bson:"day"`
+	Views     int64              `bson:"views"`
+	Carts     int64              `bson:"carts"`
+	Purchases int64              `bson:"purchases"`
+}
+
+// AddProductStats adds the counters to the stored day of the product.
+func (m *MongoDB) AddProductStats(d ProductDay) error {
+	filter := bson.M{"product_id": d.ProductID, "day": Day(d.Day)}
+	update := bson.M{"$inc": bson.M{"views": d.Views, "carts": d.Carts, "purchases": d.Purchases}}
+	_, err := m.ProductStats.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetProductViews sums the views of the products in [from, to).
+func (m *MongoDB) GetProductViews(ids []primitive.ObjectID, from, to time.Time) (map[primitive.ObjectID]int64, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"product_id": bson.M{"$in": ids}, "day": bson.M{"$gte": Day(from), "$lt": to}}},
+		bson.M{"$group": bson.M{"_id": "$product_id", "views": bson.M{"$sum": "$views"}}},
+	}
+	cur, err := m.ProductStats.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	var rows []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Views int64              `bson:"views"`
+	}
+	if err := cur.All(context.TODO(), &rows); err != nil {
+		return nil, err
+	}
+	views := make(map[primitive.ObjectID]int64, len(rows))
+	for _, r := range rows {
+		views[r.ID] = r.Views
+	}
+	return views, nil
+}
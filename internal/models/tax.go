@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TaxRule describes the VAT charged on sales of VAT-registered sellers.
+// Inclusive rates are already part of the shelf price; exclusive ones are
+// added on top at checkout.
+type TaxRule struct {
+	Name      string
+	Rate      float64
+	Inclusive bool
+}
+
+// KazakhstanVAT is the standard VAT rate, included in retail prices.
+var KazakhstanVAT = TaxRule{Name: "ҚҚС", Rate: 12, Inclusive: true}
+
+// SellerInfo holds the seller details printed on receipts.
+type SellerInfo struct {
+	CompanyName   string `bson:"company_name" json:"company_name"`
+	BIN           string `bson:"bin" json:"bin"`
+	Address       string `bson:"address" json:"address"`
+	VATRegistered bool   `bson:"vat_registered" json:"vat_registered"`
+}
+
+var ErrInvalidSellerInfo = errors.New("invalid seller details")
+
+// Validate checks the business identification number (12 digits) and that
+// VAT payers give the details a receipt needs.
+func (s SellerInfo) Validate() error {
+	if s.BIN != "" {
+		if len(s.BIN) != 12 {
+			return ErrInvalidSellerInfo
+		}
+		for _, c := range s.BIN {
+			if c < '0' || c > '9' {
+				return ErrInvalidSellerInfo
+			}
+		}
+	}
+	if s.VATRegistered && (s.CompanyName == "" || s.BIN == "") {
+		return ErrInvalidSellerInfo
+	}
+	return nil
+}
+
+// Tax returns the tax on a line amount. For inclusive rules the tax is the
+// part of the amount that is VAT, otherwise it comes on top of it.
+func (t TaxRule) Tax(amount money.Money) money.Money {
+	if t.Inclusive {
+		return money.FromMinor(int64(math.Round(float64(amount.Amount) * t.Rate / (100 + t.Rate))))
+	}
+	return amount.Percent(t.Rate)
+}
+
+func (m *MongoDB) GetUser(id primitive.ObjectID) (*User, error) {
+	var u User
+	err := m.Users.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&u)
+	return &u, err
+}
+
+func (m *MongoDB) UpdateSellerInfo(id primitive.ObjectID, info SellerInfo) error {
+	if err := info.Validate(); err != nil {
+		return err
+	}
+	_, err := m.Users.UpdateOne(context.TODO(), bson.M{"_id": id, "role": "seller"}, bson.M{"$set": bson.M{"seller": info}})
+	return err
+}
+
+func (m *MongoDB) usersByID(ids []primitive.ObjectID) (map[primitive.ObjectID]*User, error) {
+	var users []*User
+	cur, err := m.Users.Find(context.TODO(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &users); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]*User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	return byID, nil
+}
+
+// ApplyTax records the seller of every order line and computes the line tax
+// for sellers registered for VAT. Discounts are taken off before the tax is
+// computed. With an exclusive rule the tax is added to the order total.
+func (m *MongoDB) ApplyTax(rule TaxRule, o *Order) error {
+	ids := make([]primitive.ObjectID, 0, len(o.Items))
+	for _, item := range o.Items {
+		ids = append(ids, item.ProductID)
+	}
+	products, err := m.productsByID(ids)
+	if err != nil {
+		return err
+	}
+
+	sellerIDs := make([]primitive.ObjectID, 0, len(products))
+	for _, p := range products {
+		sellerIDs = append(sellerIDs, p.SellerID)
+	}
+	sellers, err := m.usersByID(sellerIDs)
+	if err != nil {
+		return err
+	}
+
+	o.Tax = money.Money{}
+	o.TaxInclusive = rule.Inclusive
+	for i := range o.Items {
+		item := &o.Items[i]
+		item.Tax = money.Money{}
+		item.TaxRate = 0
+
+		p, ok := products[item.ProductID]
+		if !ok {
+			continue
+		}
+		item.SellerID = p.SellerID
+
+		seller, ok := sellers[p.SellerID]
+		if !ok || seller.Seller == nil || !seller.Seller.VATRegistered {
+			continue
+		}
+		item.TaxRate = rule.Rate
+		item.Tax = rule.Tax(item.Total().Sub(item.Discount))
+		o.Tax = o.Tax.Add(item.Tax)
+	}
+
+	if !rule.Inclusive {
+		o.TotalPrice = o.TotalPrice.Add(o.Tax)
+	}
+	return nil
+}
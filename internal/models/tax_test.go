@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"kazakh_aliexpress/internal/money"
+)
+
+func TestTaxRuleTax(t *testing.T) {
+	exclusive := TaxRule{Name: "VAT", Rate: 12}
+	tests := []struct {
+		name   string
+		rule   TaxRule
+		amount int64
+		want   int64
+	}{
+		{"inclusive exact", KazakhstanVAT, 11200, 1200},
+		{"inclusive rounded down", KazakhstanVAT, 100000, 10714},
+		{"inclusive rounded up", KazakhstanVAT, 1050, 113},
+		{"inclusive one tiyn", KazakhstanVAT, 1, 0},
+		{"inclusive zero", KazakhstanVAT, 0, 0},
+		{"exclusive", exclusive, 10000, 1200},
+		{"exclusive rounded", exclusive, 1005, 121},
+		{"exclusive one tiyn", exclusive, 1, 0},
+		{"zero rate", TaxRule{Rate: 0, Inclusive: true}, 5000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Tax(money.FromMinor(tt.amount)); got.Amount != tt.want {
+				t.Errorf("Tax(%d) = %d, want %d", tt.amount, got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+// The VAT included in a price plus the net amount gives back the price, and
+// adding exclusive VAT to that net amount comes back to the price as well.
+func TestTaxRuleSplit(t *testing.T) {
+	exclusive := TaxRule{Rate: KazakhstanVAT.Rate}
+	for _, price := range []int64{11200, 1250000, 45000, 18990} {
+		gross := money.FromMinor(price)
+		vat := KazakhstanVAT.Tax(gross)
+		net := gross.Sub(vat)
+		if net.Add(vat) != gross {
+			t.Errorf("%d: net %d + VAT %d != gross", price, net.Amount, vat.Amount)
+		}
+		if diff := net.Add(exclusive.Tax(net)).Sub(gross).Amount; diff < -1 || diff > 1 {
+			t.Errorf("%d: exclusive VAT on net %d is off by %d tiyn", price, net.Amount, diff)
+		}
+	}
+}
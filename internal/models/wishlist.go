@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kazakh_aliexpress/internal/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WishlistItem is a product a customer wants to keep an eye on. Price and
+// InStock hold what the customer was last told about the product, so the
+// watcher can notify them when it becomes cheaper or available again.
+type WishlistItem struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Name      string             `bson:"name" json:"name"`
+	Price     money.Money        `bson:"price" json:"price"`
+	InStock   bool               `bson:"in_stock" json:"in_stock"`
+	AddedAt   time.Time          `bson:"added_at" json:"added_at"`
+
+	Product *Product `bson:"-" json:"product,omitempty"`
+}
+
+func wishlistFilter(userID, productID primitive.ObjectID) bson.M {
+	return bson.M{"user_id": userID, "product_id": productID}
+}
+
+// GetWishlist returns the user's wishlist with the current state of every
+// product attached. Product is nil for products that were deleted.
+func (m *MongoDB) GetWishlist(userID primitive.ObjectID) ([]*WishlistItem, error) {
+	var items []*WishlistItem
+	opts := options.Find().SetSort(bson.M{"added_at": -1})
+	cur, err := m.Wishlists.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &items); err != nil {
+		return nil, err
+	}
+
+	products, err := m.productsByID(wishlistProductIDs(items))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		item.Product = products[item.ProductID]
+	}
+	return items, nil
+}
+
+// GetWishlistedIDs returns the hex ids of the products in the user's wishlist.
+func (m *MongoDB) GetWishlistedIDs(userID primitive.ObjectID) (map[string]bool, error) {
+	var items []*WishlistItem
+	opts := options.Find().SetProjection(bson.M{"product_id": 1})
+	cur, err := m.Wishlists.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &items); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(items))
+	for _, item := range items {
+		ids[item.ProductID.Hex()] = true
+	}
+	return ids, nil
+}
+
+func (m *MongoDB) AddToWishlist(userID primitive.ObjectID, product *Product) error {
+	update := bson.M{
+		"$set": bson.M{
+			"name":     product.Name,
+			"price":    product.Price,
+			"in_stock": product.Stock > 0,
+		},
+		"$setOnInsert": bson.M{"added_at": time.Now()},
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err := m.Wishlists.UpdateOne(context.TODO(), wishlistFilter(userID, product.ID), update, opts)
+	return err
+}
+
+func (m *MongoDB) RemoveFromWishlist(userID, productID primitive.ObjectID) error {
+	_, err := m.Wishlists.DeleteOne(context.TODO(), wishlistFilter(userID, productID))
+	return err
+}
+
+// SaveForLater moves a cart line into the user's wishlist.
+func (m *MongoDB) SaveForLater(userID primitive.ObjectID, product *Product) error {
+	if err := m.AddToWishlist(userID, product); err != nil {
+		return err
+	}
+	return m.RemoveFromCart(CartOwner{UserID: userID}, product.ID)
+}
+
+// MoveToCart puts one unit of a wishlisted product into the cart and drops it
+// from the wishlist. Sold out products stay in the wishlist.
+func (m *MongoDB) MoveToCart(userID primitive.ObjectID, product *Product) error {
+	if err := m.AddToCart(CartOwner{UserID: userID}, product, 1); err != nil {
+		return err
+	}
+	return m.RemoveFromWishlist(userID, product.ID)
+}
+
+// NotifyWishlistChanges compares every wishlist entry with the current
+// product and notifies the owner when the price went down or the product is
+// back in stock. It returns the number of notifications sent.
+func (m *MongoDB) NotifyWishlistChanges() (int, error) {
+	var items []*WishlistItem
+	cur, err := m.Wishlists.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &items); err != nil {
+		return 0, err
+	}
+
+	products, err := m.productsByID(wishlistProductIDs(items))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, item := range items {
+		p, ok := products[item.ProductID]
+		if !ok {
+			continue
+		}
+		inStock := p.Stock > 0
+		if p.Price.Amount == item.Price.Amount && inStock == item.InStock {
+			continue
+		}
+
+		var message string
+		switch {
+		case inStock && !item.InStock:
+			message = fmt.Sprintf("«%s» қоймаға қайта түсті", p.Name)
+		case inStock && p.Price.Less(item.Price):
+			message = fmt.Sprintf("«%s» арзандады: %s → %s", p.Name, item.Price, p.Price)
+		}
+		if message != "" {
+			err := m.Notify(Notification{
+				UserID:  item.UserID,
+				Message: message,
+				Link:    "/product?id=" + p.ID.Hex(),
+			})
+			if err != nil {
+				return sent, err
+			}
+			sent++
+		}
+
+		update := bson.M{"$set": bson.M{"name": p.Name, "price": p.Price, "in_stock": inStock}}
+		if _, err := m.Wishlists.UpdateOne(context.TODO(), bson.M{"_id": item.ID}, update); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func wishlistProductIDs(items []*WishlistItem) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+	return ids
+}
+
+func (m *MongoDB) productsByID(ids []primitive.ObjectID) (map[primitive.ObjectID]*Product, error) {
+	byID := make(map[primitive.ObjectID]*Product, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+
+	var products []*Product
+	cur, err := m.Products.Find(context.TODO(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	if err = cur.All(context.TODO(), &products); err != nil {
+		return nil, err
+	}
+
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	return byID, nil
+}
@@ -0,0 +1,112 @@
+package moderation
+
+import (
+	"bufio"
+	"embed"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+//go:embed wordlists/*.txt
+var defaultLists embed.FS
+
+const (
+	FlagProfanity = "profanity"
+	FlagLink      = "link"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.|\b[\p{L}0-9-]+\.(kz|ru|com|net|org|info|biz|io|me|su|xyz|ly)\b|t\.me/|@[a-z0-9_]{5,})`)
+
+// Filter flags review text that contains words from the configured lists or
+// links to other sites. Suspicious reviews are held for moderation.
+type Filter struct {
+	words    map[string]bool
+	prefixes []string
+}
+
+// LoadFilter reads kk.txt, ru.txt and en.txt word lists from dir. Lists
+// missing from dir, or every list when dir is empty, fall back to the
+// built-in defaults.
+func LoadFilter(dir string) (*Filter, error) {
+	f := &Filter{words: make(map[string]bool)}
+
+	for _, lang := range []string{"kk", "ru", "en"} {
+		name := lang + ".txt"
+
+		var r io.ReadCloser
+		var err error
+		if dir != "" {
+			r, err = os.Open(filepath.Join(dir, name))
+		}
+		if dir == "" || os.IsNotExist(err) {
+			r, err = defaultLists.Open("wordlists/" + name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = f.load(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (f *Filter) load(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := normalize(strings.TrimSpace(sc.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(line, "*"); ok {
+			f.prefixes = append(f.prefixes, prefix)
+		} else {
+			f.words[line] = true
+		}
+	}
+	return sc.Err()
+}
+
+// Check returns the reasons the text should be held for moderation, or nil if
+// it looks clean.
+func (f *Filter) Check(text string) []string {
+	var flags []string
+
+	if f.hasBannedWord(text) {
+		flags = append(flags, FlagProfanity)
+	}
+	if linkPattern.MatchString(text) {
+		flags = append(flags, FlagLink)
+	}
+	return flags
+}
+
+func (f *Filter) hasBannedWord(text string) bool {
+	tokens := strings.FieldsFunc(normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, tok := range tokens {
+		if f.words[tok] {
+			return true
+		}
+		for _, p := range f.prefixes {
+			if strings.HasPrefix(tok, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalize(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+}
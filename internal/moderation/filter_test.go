@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFilterCheck(t *testing.T) {
+	f, err := LoadFilter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Жақсы тауар, жеткізу жылдам болды", nil},
+		{"Отличный чайник, рекомендую", nil},
+		{"Сатушы ақымақ екен", []string{FlagProfanity}},
+		{"Сатушы АҚЫМАҚТАР", []string{FlagProfanity}},
+		{"What a shitty kettle", []string{FlagProfanity}},
+		{"Бля, сломался через день", []string{FlagProfanity}},
+		{"Арзанырақ мында: https://example.com", []string{FlagLink}},
+		{"Заходите на shop.kz", []string{FlagLink}},
+		{"Пишите в t.me/sellerbot", []string{FlagLink}},
+		{"Пишите @cheap_seller", []string{FlagLink}},
+		{"ақымақ баға, www.example.com", []string{FlagProfanity, FlagLink}},
+		// Parts of longer words and dotted version numbers are fine.
+		{"Скрипт жұмыс істейді, нұсқа 2.0", nil},
+	}
+	for _, tt := range tests {
+		if got := f.Check(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Check(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// A list in the configured directory replaces the built-in one for its
+// language only.
+func TestLoadFilterDir(t *testing.T) {
+	dir := t.TempDir()
+	list := "# жергілікті тізім\n\nсасық*\nЁлка\n"
+	if err := os.WriteFile(filepath.Join(dir, "kk.txt"), []byte(list), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LoadFilter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"сасықтау иіс", true},
+		{"елка", true},
+		{"ақымақ", false},
+		{"бля", true},
+		{"жергілікті", false},
+	}
+	for _, tt := range tests {
+		if got := f.Check(tt.text) != nil; got != tt.want {
+			t.Errorf("Check(%q) flagged = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package money represents amounts as integer minor units (tiyn for tenge)
+// so that sums and discounts never pick up floating point rounding errors.
+package money
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const KZT = "KZT"
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Money is an amount in minor units of its currency. The zero value is zero
+// tenge.
+type Money struct {
+	Amount   int64  `bson:"amount" json:"amount"`
+	Currency string `bson:"currency" json:"currency"`
+}
+
+// Tenge returns an amount of whole tenge.
+func Tenge(tenge int64) Money {
+	return Money{Amount: tenge * 100, Currency: KZT}
+}
+
+// FromMinor returns an amount given in tiyn.
+func FromMinor(minor int64) Money {
+	return Money{Amount: minor, Currency: KZT}
+}
+
+// Parse reads a decimal amount in tenge as typed into a form: "12500",
+// "12 500", "12500.5" or "12500,50". More than two decimals are rejected.
+func Parse(s string) (Money, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\u00a0', '\u2009', '\u202f':
+			return -1
+		case ',':
+			return '.'
+		}
+		return r
+	}, strings.TrimSpace(s))
+
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(frac) > 2 {
+		return Money{}, ErrInvalidAmount
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	major, err := strconv.ParseUint(whole, 10, 56)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+	minor, err := strconv.ParseUint(frac, 10, 8)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+
+	amount := int64(major)*100 + int64(minor)
+	if neg {
+		amount = -amount
+	}
+	return FromMinor(amount), nil
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return KZT
+	}
+	return m.Currency
+}
+
+// sameCurrency panics when two amounts cannot be combined. Mixing
+// currencies is always a programming error: converted amounts are for
+// display only.
+func (m Money) sameCurrency(o Money) {
+	if m.currency() != o.currency() {
+		panic("money: " + m.currency() + " and " + o.currency() + " amounts mixed")
+	}
+}
+
+// Add returns the sum of two amounts. It panics if their currencies differ.
+func (m Money) Add(o Money) Money {
+	m.sameCurrency(o)
+	return Money{Amount: m.Amount + o.Amount, Currency: m.currency()}
+}
+
+// Sub returns the difference of two amounts. It panics if their currencies
+// differ.
+func (m Money) Sub(o Money) Money {
+	m.sameCurrency(o)
+	return Money{Amount: m.Amount - o.Amount, Currency: m.currency()}
+}
+
+// Mul multiplies the amount by a quantity.
+func (m Money) Mul(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.currency()}
+}
+
+// Percent returns p percent of the amount, rounded half away from zero.
+func (m Money) Percent(p float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * p / 100)), Currency: m.currency()}
+}
+
+// Share returns part/whole of the amount, rounded half away from zero. It is
+// used to pro-rate refunds for part of an order line.
+func (m Money) Share(part, whole int64) Money {
+	if whole == 0 {
+		return Money{Currency: m.currency()}
+	}
+	return Money{Amount: int64(math.Round(float64(m.Amount) * float64(part) / float64(whole))), Currency: m.currency()}
+}
+
+// Allocate splits the amount in proportion to the weights. The parts always
+// add up to the original amount: leftover minor units go to the parts with
+// the largest remainders. A negative amount is split like its absolute value.
+func (m Money) Allocate(weights []int64) []Money {
+	if m.Amount < 0 {
+		parts := Money{Amount: -m.Amount, Currency: m.currency()}.Allocate(weights)
+		for i := range parts {
+			parts[i].Amount = -parts[i].Amount
+		}
+		return parts
+	}
+
+	parts := make([]Money, len(weights))
+	var total int64
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		for i := range parts {
+			parts[i] = Money{Currency: m.currency()}
+		}
+		return parts
+	}
+
+	remainders := make([]int64, len(weights))
+	left := m.Amount
+	for i, w := range weights {
+		parts[i] = Money{Amount: m.Amount * w / total, Currency: m.currency()}
+		remainders[i] = m.Amount * w % total
+		left -= parts[i].Amount
+	}
+	for ; left > 0; left-- {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		parts[best].Amount++
+		remainders[best] = -1
+	}
+	return parts
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) Less(o Money) bool {
+	return m.Amount < o.Amount
+}
+
+func Min(a, b Money) Money {
+	if b.Less(a) {
+		return b
+	}
+	return a
+}
+
+// Major returns the amount in whole currency units. Use it only for display
+// computations such as charts, never for sums.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / 100
+}
+
+// Decimal formats the amount for form inputs: "12500" or "12500.50".
+func (m Money) Decimal() string {
+	s := strconv.FormatInt(abs(m.Amount)/100, 10)
+	if frac := abs(m.Amount) % 100; frac != 0 {
+		s += "." + pad2(frac)
+	}
+	if m.Amount < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// String formats the amount for people: thousands separated by thin spaces,
+// a decimal comma when there are tiyn, and the currency sign, e.g. "12 500 ₸".
+func (m Money) String() string {
+	digits := strconv.FormatInt(abs(m.Amount)/100, 10)
+
+	var b strings.Builder
+	if m.Amount < 0 {
+		b.WriteString("\u2212")
+	}
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteRune('\u2009')
+		}
+		b.WriteRune(d)
+	}
+	if frac := abs(m.Amount) % 100; frac != 0 {
+		b.WriteString("," + pad2(frac))
+	}
+	b.WriteString("\u00a0" + Symbol(m.currency()))
+	return b.String()
+}
+
+// Symbol returns the sign of a currency, or its code if it has none.
+func Symbol(currency string) string {
+	switch currency {
+	case KZT:
+		return "₸"
+	case "RUB":
+		return "₽"
+	case "USD":
+		return "$"
+	case "EUR":
+		return "€"
+	}
+	return currency
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func pad2(n int64) string {
+	if n < 10 {
+		return "0" + strconv.FormatInt(n, 10)
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,112 @@
+package money
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  error
+	}{
+		{"12500", 1250000, nil},
+		{"12 500", 1250000, nil},
+		{"12\u00a0500", 1250000, nil},
+		{"1\u2009250\u202f000", 125000000, nil},
+		{" 7 ", 700, nil},
+		{"12500.5", 1250050, nil},
+		{"12500,50", 1250050, nil},
+		{"12 500,05", 1250005, nil},
+		{"0,05", 5, nil},
+		{"-3,5", -350, nil},
+		{"-0.01", -1, nil},
+		{"1.234", 0, ErrInvalidAmount},
+		{"1,005", 0, ErrInvalidAmount},
+		{"1.2.3", 0, ErrInvalidAmount},
+		{",5", 0, ErrInvalidAmount},
+		{"-", 0, ErrInvalidAmount},
+		{"", 0, ErrInvalidAmount},
+		{"12a", 0, ErrInvalidAmount},
+		{"1,-5", 0, ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			}
+			if err == nil && got != FromMinor(tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %d tiyn", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		weights []int64
+		want    []int64
+	}{
+		{"even", 900, []int64{1, 1, 1}, []int64{300, 300, 300}},
+		{"remainder to first of equal", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"remainder to largest", 1000, []int64{1, 2}, []int64{333, 667}},
+		{"several remainders", 7, []int64{1, 1, 1, 1}, []int64{2, 2, 2, 1}},
+		{"zero weight", 10, []int64{3, 0, 7}, []int64{3, 0, 7}},
+		{"all weights zero", 5, []int64{0, 0}, []int64{0, 0}},
+		{"line totals", 1001, []int64{2500, 7500}, []int64{250, 751}},
+		{"negative", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{"negative remainder to largest", -1000, []int64{1, 2}, []int64{-333, -667}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := FromMinor(tt.amount).Allocate(tt.weights)
+			got := make([]int64, len(parts))
+			for i, p := range parts {
+				got[i] = p.Amount
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Allocate(%d, %v) = %v, want %v", tt.amount, tt.weights, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCurrencyMismatch(t *testing.T) {
+	if got := Tenge(1).Add(Money{Amount: 50}); got != FromMinor(150) {
+		t.Errorf("Add with empty currency = %+v, want 150 tiyn", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Add of KZT and USD did not panic")
+		}
+	}()
+	Tenge(1).Add(Money{Amount: 100, Currency: "USD"})
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount int64
+		p      float64
+		want   int64
+	}{
+		{1000, 12, 120},
+		{1000, 0, 0},
+		{1000, 100, 1000},
+		{1005, 10, 101},
+		{-1005, 10, -101},
+		{1, 50, 1},
+		{1, 49, 0},
+		{999, 12.5, 125},
+	}
+	for _, tt := range tests {
+		if got := FromMinor(tt.amount).Percent(tt.p); got != FromMinor(tt.want) {
+			t.Errorf("Percent(%d, %v) = %d, want %d", tt.amount, tt.p, got.Amount, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package money
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rates holds how many tenge one unit of a foreign currency costs. It is used
+// only to show approximate prices; everything is charged in tenge.
+type Rates map[string]float64
+
+// LoadRates reads a rates table with one "CODE rate" pair per line, e.g.
+// "USD 505.2". Blank lines and lines starting with # are skipped. An empty
+// path means no conversion.
+func LoadRates(path string) (Rates, error) {
+	rates := Rates{}
+	if path == "" {
+		return rates, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"CODE rate\"", path, line)
+		}
+		rate, err := strconv.ParseFloat(strings.Replace(fields[1], ",", ".", 1), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("%s:%d: invalid rate %q", path, line, fields[1])
+		}
+		rates[strings.ToUpper(fields[0])] = rate
+	}
+	return rates, scanner.Err()
+}
+
+// Currencies lists the currencies prices can be shown in, tenge first.
+func (r Rates) Currencies() []string {
+	codes := []string{KZT}
+	for code := range r {
+		if code != KZT {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes[1:])
+	return codes
+}
+
+var errUnknownCurrency = errors.New("unknown currency")
+
+// Convert turns a tenge amount into the given currency.
+func (r Rates) Convert(m Money, currency string) (Money, error) {
+	if currency == m.currency() {
+		return m, nil
+	}
+	rate, ok := r[currency]
+	if !ok || m.currency() != KZT {
+		return Money{}, errUnknownCurrency
+	}
+	return Money{Amount: int64(math.Round(float64(m.Amount) / rate)), Currency: currency}, nil
+}
+
+// Display formats a tenge amount and, when another currency is chosen and
+// known, appends its approximate value: "12 500 ₸ ≈ 24,75 $".
+func (r Rates) Display(m Money, currency string) string {
+	if currency == "" || currency == m.currency() {
+		return m.String()
+	}
+	converted, err := r.Convert(m, currency)
+	if err != nil {
+		return m.String()
+	}
+	return m.String() + " ≈ " + converted.String()
+}
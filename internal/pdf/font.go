@@ -0,0 +1,364 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+var ErrUnsupportedFont = errors.New("unsupported font: a TrueType font with a Unicode cmap is required")
+
+// Font is a TrueType font. Documents embed a subset of it with only the
+// glyphs they draw. Any Unicode font works; DejaVu Sans or PT Sans cover
+// Russian and Kazakh.
+type Font struct {
+	name       string
+	tables     map[string]reader
+	loca       []int
+	unitsPerEm int
+	glyphs     map[rune]uint16
+	advances   []uint16
+	bbox       [4]int
+	ascent     int
+	descent    int
+	capHeight  int
+	italic     float64
+}
+
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFont(data)
+}
+
+// ParseFont reads the tables needed to lay out text and describe the font in
+// a PDF. OpenType fonts with CFF outlines are not supported.
+func ParseFont(data []byte) (*Font, error) {
+	r := reader(data)
+	if len(data) < 12 || r.u32(0) == 0x4F54544F { // "OTTO"
+		return nil, ErrUnsupportedFont
+	}
+
+	tables := map[string]reader{}
+	numTables := int(r.u16(4))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, ErrUnsupportedFont
+		}
+		tag := string(data[rec : rec+4])
+		off, length := int(r.u32(rec+8)), int(r.u32(rec+12))
+		if off+length > len(data) {
+			return nil, ErrUnsupportedFont
+		}
+		tables[tag] = reader(data[off : off+length])
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "cmap", "glyf", "loca"} {
+		if _, ok := tables[tag]; !ok {
+			return nil, ErrUnsupportedFont
+		}
+	}
+
+	head, hhea := tables["head"], tables["hhea"]
+	if len(head) < 54 || len(hhea) < 36 || len(tables["maxp"]) < 6 {
+		return nil, ErrUnsupportedFont
+	}
+	f := &Font{
+		name:       "EmbeddedFont",
+		tables:     tables,
+		unitsPerEm: int(head.u16(18)),
+		ascent:     int(hhea.i16(4)),
+		descent:    int(hhea.i16(6)),
+	}
+	if f.unitsPerEm == 0 {
+		return nil, ErrUnsupportedFont
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int(head.i16(36 + 2*i))
+	}
+	f.capHeight = f.ascent
+	if os2, ok := tables["OS/2"]; ok && len(os2) >= 90 && os2.u16(0) >= 2 {
+		if c := int(os2.i16(88)); c > 0 {
+			f.capHeight = c
+		}
+	}
+	if post, ok := tables["post"]; ok && len(post) >= 8 {
+		f.italic = float64(int32(post.u32(4))) / 65536
+	}
+	if name := postScriptName(tables["name"]); name != "" {
+		f.name = name
+	}
+
+	numGlyphs := int(tables["maxp"].u16(4))
+	numMetrics := int(hhea.u16(34))
+	hmtx := tables["hmtx"]
+	if numGlyphs == 0 || numMetrics == 0 || len(hmtx) < 4*numMetrics {
+		return nil, ErrUnsupportedFont
+	}
+	f.advances = make([]uint16, numGlyphs)
+	for g := range f.advances {
+		if g < numMetrics {
+			f.advances[g] = hmtx.u16(4 * g)
+		} else {
+			f.advances[g] = f.advances[numMetrics-1]
+		}
+	}
+
+	loca, err := parseLoca(tables["loca"], head.i16(50), numGlyphs, len(tables["glyf"]))
+	if err != nil {
+		return nil, err
+	}
+	f.loca = loca
+
+	glyphs, err := parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	for r, g := range glyphs {
+		if int(g) >= numGlyphs {
+			delete(glyphs, r)
+		}
+	}
+	f.glyphs = glyphs
+	return f, nil
+}
+
+// HasGlyph reports whether the font can draw the rune.
+func (f *Font) HasGlyph(r rune) bool {
+	_, ok := f.glyphs[r]
+	return ok
+}
+
+// Width returns the width of s in points at the given font size.
+func (f *Font) Width(s string, size float64) float64 {
+	var units int
+	for _, r := range f.substitute(s) {
+		units += int(f.advances[f.glyphs[r]])
+	}
+	return float64(units) * size / float64(f.unitsPerEm)
+}
+
+// Wrap breaks s into lines no wider than width, splitting at spaces. Words
+// longer than a line are cut, but every line gets at least one character.
+func (f *Font) Wrap(s string, size, width float64) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if f.Width(candidate, size) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = word
+		for f.Width(line, size) > width {
+			runes := []rune(line)
+			n := len(runes) - 1
+			for n > 1 && f.Width(string(runes[:n]), size) > width {
+				n--
+			}
+			n = max(n, 1)
+			lines = append(lines, string(runes[:n]))
+			line = string(runes[n:])
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fallbacks replace characters that are commonly missing from fonts, such as
+// the thin space and the tenge sign used by money formatting.
+var fallbacks = map[rune]string{
+	'\u00a0': " ",
+	'\u2009': " ",
+	'\u202f': " ",
+	'\u2212': "-",
+	'\u2013': "-",
+	'\u2014': "-",
+	'\u20b8': "тг",
+	'\u2248': "~",
+	'\u00ab': "\"",
+	'\u00bb': "\"",
+}
+
+func (f *Font) substitute(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !f.HasGlyph(r) {
+			if alt, ok := fallbacks[r]; ok {
+				b.WriteString(alt)
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// parseLoca returns the offsets of the glyphs in the glyf table, one more
+// than there are glyphs so that glyph g spans loca[g] to loca[g+1].
+func parseLoca(loca reader, format int16, numGlyphs, glyfLen int) ([]int, error) {
+	offsets := make([]int, numGlyphs+1)
+	size := 2
+	if format == 1 {
+		size = 4
+	}
+	if len(loca) < size*len(offsets) {
+		return nil, ErrUnsupportedFont
+	}
+	for g := range offsets {
+		if format == 1 {
+			offsets[g] = int(loca.u32(4 * g))
+		} else {
+			offsets[g] = 2 * int(loca.u16(2*g))
+		}
+		if offsets[g] > glyfLen || g > 0 && offsets[g] < offsets[g-1] {
+			return nil, ErrUnsupportedFont
+		}
+	}
+	return offsets, nil
+}
+
+// parseCmap builds the rune to glyph mapping from the best Unicode subtable:
+// format 12 covers all planes, format 4 the basic multilingual plane.
+func parseCmap(cmap reader) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, ErrUnsupportedFont
+	}
+	best, bestScore := -1, 0
+	for i := 0; i < int(cmap.u16(2)); i++ {
+		rec := 4 + 8*i
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform, encoding, off := cmap.u16(rec), cmap.u16(rec+2), int(cmap.u32(rec+4))
+		if off+2 > len(cmap) {
+			continue
+		}
+		score := 0
+		switch format := cmap.u16(off); {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			score = 3
+		case format == 4 && platform == 3 && encoding == 1:
+			score = 2
+		case format == 4 && platform == 0:
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = off, score
+		}
+	}
+	if best < 0 {
+		return nil, ErrUnsupportedFont
+	}
+
+	glyphs := map[rune]uint16{}
+	t := cmap[best:]
+	if t.u16(0) == 12 {
+		if len(t) < 16 {
+			return nil, ErrUnsupportedFont
+		}
+		for i := 0; i < int(t.u32(12)); i++ {
+			g := 16 + 12*i
+			if g+12 > len(t) {
+				return nil, ErrUnsupportedFont
+			}
+			start, end, glyph := t.u32(g), t.u32(g+4), t.u32(g+8)
+			for c := start; c <= end && c <= 0x10FFFF; c++ {
+				glyphs[rune(c)] = uint16(glyph + c - start)
+			}
+		}
+		return glyphs, nil
+	}
+
+	if len(t) < 14 {
+		return nil, ErrUnsupportedFont
+	}
+	segs := int(t.u16(6)) / 2
+	ends, starts, deltas, offsets := 14, 16+2*segs, 16+4*segs, 16+6*segs
+	if offsets+2*segs > len(t) {
+		return nil, ErrUnsupportedFont
+	}
+	for s := 0; s < segs; s++ {
+		start, end := int(t.u16(starts+2*s)), int(t.u16(ends+2*s))
+		delta, rangeOffset := t.u16(deltas+2*s), int(t.u16(offsets+2*s))
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			var glyph uint16
+			if rangeOffset == 0 {
+				glyph = uint16(c) + delta
+			} else {
+				addr := offsets + 2*s + rangeOffset + 2*(c-start)
+				if addr+2 > len(t) {
+					continue
+				}
+				if glyph = t.u16(addr); glyph != 0 {
+					glyph += delta
+				}
+			}
+			if glyph != 0 {
+				glyphs[rune(c)] = glyph
+			}
+		}
+	}
+	return glyphs, nil
+}
+
+// postScriptName returns name ID 6, limited to the characters allowed in a
+// PDF name.
+func postScriptName(name reader) string {
+	if len(name) < 6 {
+		return ""
+	}
+	count, storage := int(name.u16(2)), int(name.u16(4))
+	for i := 0; i < count; i++ {
+		rec := 6 + 12*i
+		if rec+12 > len(name) {
+			break
+		}
+		platform, id := name.u16(rec), name.u16(rec+6)
+		length, off := int(name.u16(rec+8)), storage+int(name.u16(rec+10))
+		if id != 6 || off+length > len(name) {
+			continue
+		}
+
+		raw := name[off : off+length]
+		var s string
+		if platform == 3 || platform == 0 {
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = raw.u16(2 * j)
+			}
+			s = string(utf16.Decode(units))
+		} else {
+			s = string(raw)
+		}
+
+		s = strings.Map(func(r rune) rune {
+			if r > '!' && r <= '~' && !strings.ContainsRune("()<>[]{}/%#", r) {
+				return r
+			}
+			return -1
+		}, s)
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+type reader []byte
+
+func (r reader) u16(off int) uint16 { return binary.BigEndian.Uint16(r[off:]) }
+func (r reader) i16(off int) int16  { return int16(r.u16(off)) }
+func (r reader) u32(off int) uint32 { return binary.BigEndian.Uint32(r[off:]) }
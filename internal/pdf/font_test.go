@@ -0,0 +1,266 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// testGlyphs are the glyphs of the font built by buildFont: .notdef, simple
+// glyphs, a composite of glyphs 1 and 2, a glyph wider than an em and an
+// empty space.
+var testGlyphs = []struct {
+	r       rune
+	advance uint16
+	parts   []uint16
+}{
+	{0, 500, nil},
+	{'A', 500, nil},
+	{'Б', 600, nil},
+	{'Ә', 600, []uint16{1, 2}},
+	{'W', 3000, nil},
+	{' ', 250, nil},
+}
+
+// buildFont assembles a TrueType font of testGlyphs with 1000 units per em
+// and short loca offsets. mutate, if not nil, may change the tables first.
+func buildFont(mutate func(tables map[string][]byte)) []byte {
+	be := binary.BigEndian
+	n := len(testGlyphs)
+
+	var glyf []byte
+	loca := make([]byte, 2*(n+1))
+	hmtx := make([]byte, 4*n)
+	for g, tg := range testGlyphs {
+		be.PutUint16(loca[2*g:], uint16(len(glyf)/2))
+		be.PutUint16(hmtx[4*g:], tg.advance)
+		switch {
+		case tg.r == ' ':
+		case tg.parts != nil:
+			glyph := make([]byte, 10)
+			be.PutUint16(glyph, 0xFFFF) // -1 contours: composite
+			for i, part := range tg.parts {
+				c := make([]byte, 8)
+				flags := uint16(0x0001) // arguments are words
+				if i < len(tg.parts)-1 {
+					flags |= 0x0020 // more components
+				}
+				be.PutUint16(c, flags)
+				be.PutUint16(c[2:], part)
+				glyph = append(glyph, c...)
+			}
+			glyf = append(glyf, glyph...)
+		default:
+			glyph := make([]byte, 20)
+			be.PutUint16(glyph, 1)             // one contour
+			be.PutUint16(glyph[6:], uint16(g)) // xMax tells the glyphs apart
+			glyph[14] = 0x01                   // one point, on the curve
+			glyf = append(glyf, glyph...)
+		}
+	}
+	be.PutUint16(loca[2*n:], uint16(len(glyf)/2))
+
+	head := make([]byte, 54)
+	be.PutUint32(head, 0x00010000)
+	be.PutUint16(head[18:], 1000)
+	be.PutUint16(head[38:], uint16(0xFF38)) // yMin -200
+	be.PutUint16(head[40:], 3000)
+	be.PutUint16(head[42:], 800)
+
+	hhea := make([]byte, 36)
+	be.PutUint16(hhea[4:], 800)
+	be.PutUint16(hhea[6:], uint16(0xFF38))
+	be.PutUint16(hhea[34:], uint16(n))
+
+	maxp := make([]byte, 6)
+	be.PutUint32(maxp, 0x00005000)
+	be.PutUint16(maxp[4:], uint16(n))
+
+	tables := map[string][]byte{
+		"cmap": buildCmap(),
+		"glyf": glyf,
+		"head": head,
+		"hhea": hhea,
+		"hmtx": hmtx,
+		"loca": loca,
+		"maxp": maxp,
+	}
+	if mutate != nil {
+		mutate(tables)
+	}
+	var tags []string
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return writeFont(tags, tables)
+}
+
+// buildCmap returns a cmap with one Windows Unicode subtable in format 4 and
+// a segment per glyph.
+func buildCmap() []byte {
+	be := binary.BigEndian
+	type segment struct{ c, g uint16 }
+	var segs []segment
+	for g, tg := range testGlyphs[1:] {
+		segs = append(segs, segment{uint16(tg.r), uint16(g + 1)})
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].c < segs[j].c })
+	segs = append(segs, segment{0xFFFF, 0})
+
+	n := len(segs)
+	t := make([]byte, 16+8*n)
+	be.PutUint16(t, 4)
+	be.PutUint16(t[2:], uint16(len(t)))
+	be.PutUint16(t[6:], uint16(2*n))
+	for i, s := range segs {
+		be.PutUint16(t[14+2*i:], s.c)
+		be.PutUint16(t[16+2*n+2*i:], s.c)
+		be.PutUint16(t[16+4*n+2*i:], s.g-s.c)
+	}
+
+	cmap := make([]byte, 12)
+	be.PutUint16(cmap[2:], 1)
+	be.PutUint16(cmap[4:], 3)
+	be.PutUint16(cmap[6:], 1)
+	be.PutUint32(cmap[8:], 12)
+	return append(cmap, t...)
+}
+
+func testFont(t *testing.T) *Font {
+	t.Helper()
+	f, err := ParseFont(buildFont(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestParseFont(t *testing.T) {
+	f := testFont(t)
+	if f.unitsPerEm != 1000 || f.ascent != 800 || f.descent != -200 {
+		t.Errorf("metrics = %d %d %d, want 1000 800 -200", f.unitsPerEm, f.ascent, f.descent)
+	}
+	for g, tg := range testGlyphs[1:] {
+		if got := f.glyphs[tg.r]; int(got) != g+1 {
+			t.Errorf("glyph of %q = %d, want %d", tg.r, got, g+1)
+		}
+	}
+	if f.HasGlyph('Z') {
+		t.Error("HasGlyph('Z') = true for a rune the font lacks")
+	}
+	if f.name != "EmbeddedFont" {
+		t.Errorf("name = %q, want the default for fonts without a name table", f.name)
+	}
+}
+
+func TestParseFontMalformed(t *testing.T) {
+	be := binary.BigEndian
+	tests := []struct {
+		name   string
+		mutate func(tables map[string][]byte)
+		data   func([]byte) []byte
+	}{
+		{name: "empty", data: func([]byte) []byte { return nil }},
+		{name: "truncated", data: func(b []byte) []byte { return b[:len(b)/2] }},
+		{name: "cff outlines", data: func(b []byte) []byte { return append([]byte("OTTO"), b[4:]...) }},
+		{name: "too many tables", data: func(b []byte) []byte { be.PutUint16(b[4:], 1000); return b }},
+		{name: "missing loca", mutate: func(t map[string][]byte) { delete(t, "loca") }},
+		{name: "missing cmap", mutate: func(t map[string][]byte) { delete(t, "cmap") }},
+		{name: "short head", mutate: func(t map[string][]byte) { t["head"] = t["head"][:20] }},
+		{name: "zero units per em", mutate: func(t map[string][]byte) { be.PutUint16(t["head"][18:], 0) }},
+		{name: "no glyphs", mutate: func(t map[string][]byte) { be.PutUint16(t["maxp"][4:], 0) }},
+		{name: "short hmtx", mutate: func(t map[string][]byte) { t["hmtx"] = t["hmtx"][:8] }},
+		{name: "short loca", mutate: func(t map[string][]byte) { t["loca"] = t["loca"][:4] }},
+		{name: "loca past glyf", mutate: func(t map[string][]byte) { be.PutUint16(t["loca"][2*len(testGlyphs):], 0xFFFF) }},
+		{name: "loca decreasing", mutate: func(t map[string][]byte) { be.PutUint16(t["loca"][2:], 0xFFF) }},
+		{name: "no unicode cmap", mutate: func(t map[string][]byte) { be.PutUint16(t["cmap"][4:], 1) }},
+		{name: "cmap offset past end", mutate: func(t map[string][]byte) { be.PutUint32(t["cmap"][8:], 1<<20) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildFont(tt.mutate)
+			if tt.data != nil {
+				data = tt.data(data)
+			}
+			if _, err := ParseFont(data); !errors.Is(err, ErrUnsupportedFont) {
+				t.Errorf("ParseFont error = %v, want ErrUnsupportedFont", err)
+			}
+		})
+	}
+}
+
+// TestParseFontCorrupt feeds truncated and corrupted fonts to ParseFont,
+// which must return an error or a usable font but never panic.
+func TestParseFontCorrupt(t *testing.T) {
+	data := buildFont(nil)
+	use := func(f *Font) {
+		f.Width("AБӘW Z", 10)
+		doc := New(f, "")
+		doc.AddPage().Text(0, 0, 10, "AБӘW Z")
+		doc.Bytes()
+	}
+	for n := range data {
+		if f, err := ParseFont(data[:n]); err == nil {
+			use(f)
+		}
+	}
+	for i := range data {
+		for _, v := range []byte{0x00, 0x7F, 0xFF} {
+			corrupt := slices.Clone(data)
+			corrupt[i] = v
+			if f, err := ParseFont(corrupt); err == nil {
+				use(f)
+			}
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	f := testFont(t)
+	tests := []struct {
+		s    string
+		want float64
+	}{
+		{"", 0},
+		{"A", 5},
+		{"AБ", 11},
+		{"A A", 12.5},
+		{"A\u00a0A", 12.5}, // missing no-break space falls back to a space
+		{"Z", 5},           // missing rune is drawn as .notdef
+	}
+	for _, tt := range tests {
+		if got := f.Width(tt.s, 10); got != tt.want {
+			t.Errorf("Width(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	f := testFont(t)
+	tests := []struct {
+		s     string
+		width float64
+		want  []string
+	}{
+		{"", 100, []string{""}},
+		{"A A A", 100, []string{"A A A"}},
+		{"A A A", 13, []string{"A A", "A"}},
+		{"A A A", 12, []string{"A", "A", "A"}},
+		{"  A   A  ", 100, []string{"A A"}},
+		{"AAAAA", 12, []string{"AA", "AA", "A"}},
+		{"A AAAAA", 12, []string{"A", "AA", "AA", "A"}},
+		// Characters wider than the line still get a line each.
+		{"W", 10, []string{"W"}},
+		{"WW", 10, []string{"W", "W"}},
+		{"A W A", 10, []string{"A", "W", "A"}},
+		{"A", 0, []string{"A"}},
+	}
+	for _, tt := range tests {
+		if got := f.Wrap(tt.s, 10, tt.width); !slices.Equal(got, tt.want) {
+			t.Errorf("Wrap(%q, %v) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Package pdf writes simple PDF documents: text in one embedded TrueType
+// font, lines and filled boxes on A4 pages. It is enough for invoices and
+// statements and needs no external tools.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// A4 page size in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+type Document struct {
+	font  *Font
+	title string
+	pages []*Page
+	used  map[uint16]rune
+}
+
+// Page coordinates are in points from the top left corner of the page.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+func New(font *Font, title string) *Document {
+	return &Document{font: font, title: title, used: map[uint16]rune{}}
+}
+
+func (d *Document) Font() *Font {
+	return d.font
+}
+
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// Text draws s with its baseline at y.
+func (p *Page) Text(x, y, size float64, s string) {
+	p.text(x, y, size, s, false)
+}
+
+// BoldText draws s with a stroked outline, which reads as bold without
+// embedding a second font.
+func (p *Page) BoldText(x, y, size float64, s string) {
+	p.text(x, y, size, s, true)
+}
+
+// TextRight draws s so that it ends at x.
+func (p *Page) TextRight(x, y, size float64, s string) {
+	p.text(x-p.doc.font.Width(s, size), y, size, s, false)
+}
+
+func (p *Page) text(x, y, size float64, s string, bold bool) {
+	if s == "" {
+		return
+	}
+	var hex strings.Builder
+	for _, r := range p.doc.font.substitute(s) {
+		g := p.doc.font.glyphs[r]
+		if _, ok := p.doc.used[g]; !ok || g == 0 {
+			p.doc.used[g] = r
+		}
+		fmt.Fprintf(&hex, "%04X", g)
+	}
+
+	if bold {
+		fmt.Fprintf(&p.content, "q %s w 2 Tr ", num(size/30))
+	}
+	fmt.Fprintf(&p.content, "BT /F1 %s Tf %s %s Td <%s> Tj ET", num(size), num(x), num(A4Height-y), hex.String())
+	if bold {
+		p.content.WriteString(" Q")
+	}
+	p.content.WriteByte('\n')
+}
+
+// Line draws a thin line.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(A4Height-y1), num(x2), num(A4Height-y2))
+}
+
+// Rect strokes a rectangle whose top left corner is at x, y.
+func (p *Page) Rect(x, y, w, h, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re S\n", num(width), num(x), num(A4Height-y-h), num(w), num(h))
+}
+
+// FillRect fills a rectangle with a shade of grey, 0 being black and 1 white.
+func (p *Page) FillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "q %s g %s %s %s %s re f Q\n", num(gray), num(x), num(A4Height-y-h), num(w), num(h))
+}
+
+// Bytes renders the document.
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	const (
+		catalogID = iota + 1
+		pagesID
+		fontID
+		cidFontID
+		descriptorID
+		fontFileID
+		toUnicodeID
+		infoID
+		firstPageID
+	)
+
+	out := &objectWriter{}
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageID+2*i)
+	}
+	out.object(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	out.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	f := d.font
+	name := subsetName(f.name, d.used)
+	out.object(fontID, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cidFontID, toUnicodeID))
+	out.object(cidFontID, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW %d /W [%s] /CIDToGIDMap /Identity >>",
+		name, descriptorID, f.scale(int(f.advances[0])), d.widths()))
+
+	flags := 32
+	if f.italic != 0 {
+		flags |= 64
+	}
+	out.object(descriptorID, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%d %d %d %d] /ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, flags, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		num(f.italic), f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), fontFileID))
+	fontFile := f.subset(d.used)
+	if err := out.stream(fontFileID, fmt.Sprintf("/Length1 %d", len(fontFile)), fontFile); err != nil {
+		return out.n, err
+	}
+	if err := out.stream(toUnicodeID, "", d.toUnicode()); err != nil {
+		return out.n, err
+	}
+	out.object(infoID, fmt.Sprintf("<< /Title %s /Producer (kazakh_aliexpress) >>", textString(d.title)))
+
+	for i, p := range d.pages {
+		pageID := firstPageID + 2*i
+		out.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesID, num(A4Width), num(A4Height), fontID, pageID+1))
+		if err := out.stream(pageID+1, "", p.content.Bytes()); err != nil {
+			return out.n, err
+		}
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(out.offsets)+1)
+	for id := 1; id <= len(out.offsets); id++ {
+		fmt.Fprintf(out, "%010d 00000 n \n", out.offsets[id])
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(out.offsets)+1, catalogID, infoID, xref)
+
+	n, err := out.WriteTo(w)
+	return n, err
+}
+
+// widths lists the advance widths of the glyphs used, in the /W array format.
+func (d *Document) widths() string {
+	glyphs := make([]int, 0, len(d.used))
+	for g := range d.used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+
+	var b strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&b, "%d [%d] ", g, d.font.scale(int(d.font.advances[g])))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// toUnicode maps glyphs back to text so that documents can be searched and
+// copied from.
+func (d *Document) toUnicode() []byte {
+	glyphs := make([]int, 0, len(d.used))
+	for g := range d.used {
+		if g != 0 {
+			glyphs = append(glyphs, int(g))
+		}
+	}
+	sort.Ints(glyphs)
+
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for len(glyphs) > 0 {
+		chunk := glyphs[:min(len(glyphs), 100)]
+		glyphs = glyphs[len(chunk):]
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			fmt.Fprintf(&b, "<%04X> <", g)
+			for _, u := range utf16.Encode([]rune{d.used[uint16(g)]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// scale converts font units to the 1000 units per em used by PDF.
+func (f *Font) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+// objectWriter collects the document and remembers where each object starts
+// for the cross-reference table.
+type objectWriter struct {
+	bytes.Buffer
+	offsets map[int]int
+	n       int64
+}
+
+func (o *objectWriter) object(id int, body string) {
+	o.begin(id)
+	fmt.Fprintf(o, "%s\nendobj\n", body)
+}
+
+func (o *objectWriter) stream(id int, extra string, data []byte) error {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	o.begin(id)
+	fmt.Fprintf(o, "<< /Length %d /Filter /FlateDecode %s>>\nstream\n", z.Len(), extra+" ")
+	o.Write(z.Bytes())
+	o.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+func (o *objectWriter) begin(id int) {
+	if o.offsets == nil {
+		o.offsets = map[int]int{}
+	}
+	o.offsets[id] = o.Len()
+	fmt.Fprintf(o, "%d 0 obj\n", id)
+}
+
+func (o *objectWriter) WriteTo(w io.Writer) (int64, error) {
+	n, err := o.Buffer.WriteTo(w)
+	o.n += n
+	return o.n, err
+}
+
+// num formats a coordinate with at most two decimals.
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
+
+// textString encodes s as a PDF text string in UTF-16 with a byte order mark.
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parsePDF checks the cross-reference table of a document and returns its
+// objects by number, with streams decompressed.
+func parsePDF(t *testing.T, data []byte) map[int]string {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Fatalf("document starts with %q", data[:min(len(data), 10)])
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no startxref at the end of the document")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	lines := strings.Split(string(data[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+
+	stream := regexp.MustCompile(`(?s)^<< /Length (\d+) /Filter /FlateDecode [^>]*>>\nstream\n`)
+	objects := map[int]string{}
+	for id := 1; id < count; id++ {
+		entry := lines[2+id]
+		off, _ := strconv.Atoi(entry[:10])
+		header := strconv.Itoa(id) + " 0 obj\n"
+		if !bytes.HasPrefix(data[off:], []byte(header)) {
+			t.Fatalf("xref entry of object %d points at %q", id, data[off:min(len(data), off+20)])
+		}
+		body := data[off+len(header):]
+		if m := stream.FindSubmatch(body); m != nil {
+			n, _ := strconv.Atoi(string(m[1]))
+			raw := body[len(m[0]):]
+			if !bytes.HasPrefix(raw[n:], []byte("\nendstream\nendobj\n")) {
+				t.Fatalf("stream of object %d is not %d bytes long", id, n)
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(raw[:n]))
+			if err != nil {
+				t.Fatalf("object %d: %v", id, err)
+			}
+			content, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("object %d: %v", id, err)
+			}
+			objects[id] = string(m[0]) + string(content)
+			continue
+		}
+		end := bytes.Index(body, []byte("\nendobj\n"))
+		if end < 0 {
+			t.Fatalf("object %d has no endobj", id)
+		}
+		objects[id] = string(body[:end])
+	}
+	return objects
+}
+
+func TestDocumentBytes(t *testing.T) {
+	f := testFont(t)
+	doc := New(f, "Шот-фактура")
+	p := doc.AddPage()
+	p.Text(50, 50, 12, "AБ")
+	p.BoldText(50, 70, 12, "Ә")
+	p.TextRight(500, 90, 12, "A₸")
+	p.Line(50, 100, 500, 100, 0.5)
+	p.Rect(50, 110, 100, 20, 1)
+	p.FillRect(50, 140, 100, 20, 0.9)
+	doc.AddPage()
+
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := parsePDF(t, data)
+
+	all := strings.Join(mapValues(objects), "\n")
+	for _, want := range []string{
+		"/Type /Pages /Kids [9 0 R 11 0 R] /Count 2",
+		"/Encoding /Identity-H",
+		"/CIDToGIDMap /Identity",
+		"/W [0 [500] 1 [500] 2 [600] 3 [600]]", // the tenge sign falls back to letters the font lacks
+		"<00010002> Tj",
+		"2 Tr",
+		"re f",
+		"<0001> <0041>",
+		"<0002> <0411>",
+		"/Title <FEFF",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("document lacks %q", want)
+		}
+	}
+
+	font := regexp.MustCompile(`/Length1 (\d+)`)
+	for _, o := range objects {
+		m := font.FindStringSubmatch(o)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		file := o[strings.Index(o, "stream\n")+len("stream\n"):]
+		if len(file) != n {
+			t.Errorf("embedded font is %d bytes, Length1 says %d", len(file), n)
+		}
+		tables := readTables(t, []byte(file))
+		if _, ok := tables["glyf"]; !ok {
+			t.Error("embedded font has no glyf table")
+		}
+	}
+}
+
+func TestDocumentWithoutPages(t *testing.T) {
+	data, err := New(testFont(t), "").Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(mapValues(parsePDF(t, data)), "\n"), "/Count 1") {
+		t.Error("a document without pages does not get an empty page")
+	}
+}
+
+func mapValues(m map[int]string) []string {
+	var values []string
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
@@ -0,0 +1,170 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+)
+
+// subsetTables are the tables a PDF reader needs to draw glyphs of an
+// embedded CIDFontType2 font. The character map is not one of them: the
+// document addresses glyphs directly.
+var subsetTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// subset returns a copy of the font in which only the used glyphs, and the
+// glyphs they are composed of, keep their outlines. Glyph ids stay the same,
+// so the document needs no remapping; a DejaVu Sans of 700 KB shrinks to a
+// few dozen kilobytes for a typical invoice.
+func (f *Font) subset(used map[uint16]rune) []byte {
+	glyf := f.tables["glyf"]
+	keep := map[int]bool{0: true}
+	queue := []int{0}
+	for g := range used {
+		if int(g) < len(f.loca)-1 && !keep[int(g)] {
+			keep[int(g)] = true
+			queue = append(queue, int(g))
+		}
+	}
+	for len(queue) > 0 {
+		g := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, c := range components(glyf[f.loca[g]:f.loca[g+1]]) {
+			if c < len(f.loca)-1 && !keep[c] {
+				keep[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	var newGlyf []byte
+	newLoca := make([]byte, 4*len(f.loca))
+	for g := 0; g < len(f.loca)-1; g++ {
+		binary.BigEndian.PutUint32(newLoca[4*g:], uint32(len(newGlyf)))
+		if keep[g] {
+			newGlyf = append(newGlyf, glyf[f.loca[g]:f.loca[g+1]]...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*(len(f.loca)-1):], uint32(len(newGlyf)))
+
+	// The head table is changed to long loca offsets and a checksum
+	// adjustment of zero, which readers of embedded fonts do not check.
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{"glyf": newGlyf, "loca": newLoca, "head": head}
+	var tags []string
+	for _, tag := range subsetTables {
+		if _, ok := tables[tag]; !ok {
+			t, ok := f.tables[tag]
+			if !ok {
+				continue
+			}
+			tables[tag] = t
+		}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return writeFont(tags, tables)
+}
+
+// components returns the glyphs a composite glyph is built from.
+func components(glyph reader) []int {
+	if len(glyph) < 10 || glyph.i16(0) >= 0 {
+		return nil
+	}
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	var glyphs []int
+	for off := 10; off+4 <= len(glyph); {
+		flags := glyph.u16(off)
+		glyphs = append(glyphs, int(glyph.u16(off+2)))
+		off += 4
+		if flags&argsAreWords != 0 {
+			off += 4
+		} else {
+			off += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			off += 2
+		case flags&haveXYScale != 0:
+			off += 4
+		case flags&haveTwoByTwo != 0:
+			off += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return glyphs
+}
+
+// writeFont assembles a TrueType file from tables given in tag order.
+func writeFont(tags []string, tables map[string][]byte) []byte {
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	out := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(out[0:], 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(n))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*n-searchRange))
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := out[12+16*i:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[4:], checksum(data))
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// subsetName prefixes the font name with the six letter tag that marks a
+// subset, derived from the glyphs so that equal subsets get equal names.
+func subsetName(name string, used map[uint16]rune) string {
+	glyphs := make([]int, 0, len(used))
+	for g := range used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+	h := fnv.New32a()
+	for _, g := range glyphs {
+		h.Write([]byte{byte(g >> 8), byte(g)})
+	}
+	sum := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = byte('A' + sum%26)
+		sum /= 26
+	}
+	return string(tag) + "+" + name
+}
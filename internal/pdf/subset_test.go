@@ -0,0 +1,104 @@
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+// readTables splits a font into its tables and checks their checksums.
+func readTables(t *testing.T, data []byte) map[string]reader {
+	t.Helper()
+	r := reader(data)
+	tables := map[string]reader{}
+	for i := 0; i < int(r.u16(4)); i++ {
+		rec := 12 + 16*i
+		tag := string(data[rec : rec+4])
+		off, length := int(r.u32(rec+8)), int(r.u32(rec+12))
+		if off%4 != 0 || off+length > len(data) {
+			t.Fatalf("table %s at %d+%d does not fit %d bytes", tag, off, length, len(data))
+		}
+		tables[tag] = reader(data[off : off+length])
+		if sum := checksum(tables[tag]); sum != r.u32(rec+4) {
+			t.Errorf("table %s checksum = %08x, recorded %08x", tag, sum, r.u32(rec+4))
+		}
+	}
+	return tables
+}
+
+func TestSubset(t *testing.T) {
+	f := testFont(t)
+	tests := []struct {
+		name string
+		used []rune
+		keep []int
+	}{
+		{"nothing used", nil, []int{0}},
+		{"simple glyph", []rune{'A'}, []int{0, 1}},
+		{"composite pulls in its parts", []rune{'Ә'}, []int{0, 1, 2, 3}},
+		{"empty glyph", []rune{' ', 'W'}, []int{0, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used := map[uint16]rune{}
+			for _, r := range tt.used {
+				used[f.glyphs[r]] = r
+			}
+			tables := readTables(t, f.subset(used))
+
+			for _, tag := range []string{"glyf", "head", "hhea", "hmtx", "loca", "maxp"} {
+				if _, ok := tables[tag]; !ok {
+					t.Errorf("subset lacks the %s table", tag)
+				}
+			}
+			if _, ok := tables["cmap"]; ok {
+				t.Error("subset keeps the cmap table")
+			}
+			if !bytes.Equal(tables["hmtx"], f.tables["hmtx"]) {
+				t.Error("subset changes the glyph metrics")
+			}
+			if format := tables["head"].i16(50); format != 1 {
+				t.Fatalf("indexToLocFormat = %d, want long offsets", format)
+			}
+
+			loca, err := parseLoca(tables["loca"], 1, len(testGlyphs), len(tables["glyf"]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for g := range testGlyphs {
+				got := tables["glyf"][loca[g]:loca[g+1]]
+				want := f.tables["glyf"][f.loca[g]:f.loca[g+1]]
+				if !contains(tt.keep, g) {
+					want = nil
+				}
+				if !bytes.Equal(bytes.TrimRight(got, "\x00"), bytes.TrimRight(want, "\x00")) {
+					t.Errorf("glyph %d = %x, want %x", g, got, want)
+				}
+				if loca[g]%4 != 0 {
+					t.Errorf("glyph %d starts at %d, not aligned to 4 bytes", g, loca[g])
+				}
+			}
+		})
+	}
+}
+
+func contains(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSubsetName(t *testing.T) {
+	a := map[uint16]rune{1: 'A', 2: 'Б'}
+	b := map[uint16]rune{2: 'Б', 1: 'A'}
+	name := subsetName("DejaVuSans", a)
+	if !regexp.MustCompile(`^[A-Z]{6}\+DejaVuSans$`).MatchString(name) {
+		t.Errorf("subsetName = %q, want a six letter tag", name)
+	}
+	if other := subsetName("DejaVuSans", b); other != name {
+		t.Errorf("equal subsets are named %q and %q", name, other)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"kazakh_aliexpress/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,13 +17,14 @@ type UserRepository struct {
 	Collection *mongo.Collection
 }
 
-func (m *UserRepository) Insert(email, password, role string) error {
+func (m *UserRepository) Insert(email, password, role string) (models.User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return models.User{}, err
 	}
 
 	user := models.User{
+		ID:           primitive.NewObjectID(),
 		Email:        email,
 		PasswordHash: string(hashedPassword),
 		Role:         role,
@@ -33,7 +35,10 @@ func (m *UserRepository) Insert(email, password, role string) error {
 	defer cancel()
 
 	_, err = m.Collection.InsertOne(ctx, user)
-	return err
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
 }
 
 func (m *UserRepository) Authenticate(email, password string) (models.User, error) {
@@ -59,3 +64,11 @@ func (m *UserRepository) Authenticate(email, password string) (models.User, erro
 
 	return user, nil
 }
+
+func (m *UserRepository) EmailTaken(email string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n, err := m.Collection.CountDocuments(ctx, bson.M{"email": email})
+	return n > 0, err
+}
@@ -0,0 +1,109 @@
+// Package spreadsheet reads and writes tables as CSV and as XLSX workbooks.
+// Only what a plain table needs is supported: the first worksheet, text and
+// numbers, no styles or formulas.
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsupportedFormat = errors.New("spreadsheet: unsupported file format")
+
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+)
+
+// Format picks the format from the file name.
+func Format(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".txt":
+		return CSV, nil
+	case ".xlsx":
+		return XLSX, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Read parses a file in the given format. Trailing empty rows are dropped.
+func Read(format string, data []byte) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch format {
+	case CSV:
+		rows, err = ReadCSV(bytes.NewReader(data))
+	case XLSX:
+		rows, err = ReadXLSX(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	for len(rows) > 0 && blank(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+func Write(format string, w io.Writer, rows [][]string) error {
+	switch format {
+	case CSV:
+		return WriteCSV(w, rows)
+	case XLSX:
+		return WriteXLSX(w, rows)
+	}
+	return ErrUnsupportedFormat
+}
+
+// ContentType is the MIME type of a format.
+func ContentType(format string) string {
+	if format == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ReadCSV reads comma or semicolon separated values. Spreadsheet programs
+// in locales with a decimal comma save with semicolons, so the separator is
+// guessed from the first line. A UTF-8 byte order mark is skipped.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	cr := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	return cr.ReadAll()
+}
+
+// WriteCSV writes comma separated values with a byte order mark, which
+// spreadsheet programs need to detect UTF-8.
+func WriteCSV(w io.Writer, rows [][]string) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+func blank(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
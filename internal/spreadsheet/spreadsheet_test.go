@@ -0,0 +1,83 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var sampleRows = [][]string{
+	{"sku", "name", "price", "stock", "description"},
+	{"A-001", "Шәйнек \"Алау\"", "12500.5", "3", "Екі литр,\nболат"},
+	{"007", "Кесе; фарфор", "0.3", "0", ""},
+	{"B-2", "  бос орын  ", "-15", "", "<b>&amp;</b>"},
+	{"C-3"},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{CSV, XLSX} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(format, &buf, sampleRows); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Read(format, buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, sampleRows) {
+				t.Errorf("round trip = %q, want %q", got, sampleRows)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want [][]string
+	}{
+		{"comma", "sku,price\nA,1.5\n", [][]string{{"sku", "price"}, {"A", "1.5"}}},
+		{"semicolon", "sku;price\nA;1,5\n", [][]string{{"sku", "price"}, {"A", "1,5"}}},
+		{"byte order mark", "\ufeffsku,price\nA,2\n", [][]string{{"sku", "price"}, {"A", "2"}}},
+		{"ragged", "sku,price,stock\nA\n", [][]string{{"sku", "price", "stock"}, {"A"}}},
+		{"trailing blank rows", "sku\nA\n,\n \n", [][]string{{"sku"}, {"A"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(CSV, []byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		err  error
+	}{
+		{"products.csv", CSV, nil},
+		{"PRODUCTS.XLSX", XLSX, nil},
+		{"export.txt", CSV, nil},
+		{"old.xls", "", ErrUnsupportedFormat},
+		{"noext", "", ErrUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.name)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Format(%q) = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := Read("ods", nil); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Read(ods) error = %v, want ErrUnsupportedFormat", err)
+	}
+}
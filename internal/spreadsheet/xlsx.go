@@ -0,0 +1,338 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidWorkbook = errors.New("spreadsheet: invalid xlsx workbook")
+
+// maxColumns and maxRows guard against cell references far to the right or
+// bottom, which would otherwise allocate huge tables. maxPartSize limits the
+// uncompressed size of each XML part, since a small upload can inflate to
+// gigabytes.
+const (
+	maxColumns  = 256
+	maxRows     = 100000
+	maxPartSize = 64 << 20
+)
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxRow struct {
+	R     int `xml:"r,attr"`
+	Cells []struct {
+		R      string   `xml:"r,attr"`
+		T      string   `xml:"t,attr"`
+		V      string   `xml:"v"`
+		Inline xlsxText `xml:"is"`
+	} `xml:"c"`
+}
+
+// ReadXLSX reads the cells of the first worksheet.
+func ReadXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidWorkbook
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodeXML(f, &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.String())
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, ErrInvalidWorkbook
+	}
+	sheet, err := readRows(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows and cells without a reference follow the previous one.
+	var rows [][]string
+	for _, row := range sheet {
+		index := row.R - 1
+		if row.R == 0 {
+			index = len(rows)
+		}
+		if index < 0 || index >= maxRows {
+			return nil, ErrInvalidWorkbook
+		}
+		for len(rows) <= index {
+			rows = append(rows, nil)
+		}
+
+		var values []string
+		for _, c := range row.Cells {
+			col := len(values)
+			if c.R != "" {
+				if col, err = columnIndex(c.R); err != nil {
+					return nil, err
+				}
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch c.T {
+			case "s":
+				n, err := strconv.Atoi(c.V)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, ErrInvalidWorkbook
+				}
+				values[col] = shared[n]
+			case "inlineStr":
+				values[col] = c.Inline.String()
+			case "b":
+				values[col] = map[string]string{"1": "true", "0": "false"}[c.V]
+			case "str", "e":
+				values[col] = c.V
+			default:
+				values[col] = formatNumber(c.V)
+			}
+		}
+		rows[index] = values
+	}
+	return rows, nil
+}
+
+// firstSheet finds the part of the first worksheet through the workbook
+// relationships.
+func firstSheet(files map[string]*zip.File) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	f, ok := files["xl/workbook.xml"]
+	r, okRels := files["xl/_rels/workbook.xml.rels"]
+	if !ok || !okRels {
+		return "", ErrInvalidWorkbook
+	}
+	if err := decodeXML(f, &wb); err != nil {
+		return "", err
+	}
+	if err := decodeXML(r, &rels); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", ErrInvalidWorkbook
+	}
+	for _, rel := range rels.Items {
+		if rel.ID == wb.Sheets[0].ID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return "", ErrInvalidWorkbook
+}
+
+// readRows decodes the rows of a worksheet one at a time and stops once
+// there are more than maxRows of them.
+func readRows(f *zip.File) ([]xlsxRow, error) {
+	rc, err := openPart(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rows []xlsxRow
+	d := xml.NewDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidWorkbook, f.Name, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, ErrInvalidWorkbook
+		}
+		var row xlsxRow
+		if err := d.DecodeElement(&row, &start); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidWorkbook, f.Name, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func decodeXML(f *zip.File, v interface{}) error {
+	rc, err := openPart(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidWorkbook, f.Name, err)
+	}
+	return nil
+}
+
+// openPart opens a part of the workbook and reads at most maxPartSize bytes
+// of it, whatever size the zip header claims.
+func openPart(f *zip.File) (io.ReadCloser, error) {
+	if f.UncompressedSize64 > maxPartSize {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidWorkbook, f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, maxPartSize), rc}, nil
+}
+
+// columnIndex turns the letters of a cell reference such as "AB12" into a
+// zero based column.
+func columnIndex(ref string) (int, error) {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A') + 1
+	}
+	if col == 0 || col > maxColumns {
+		return 0, ErrInvalidWorkbook
+	}
+	return col - 1, nil
+}
+
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// formatNumber undoes the binary noise of stored doubles, so that a price
+// typed as 0.3 reads back as 0.3.
+func formatNumber(v string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// plainNumber matches values that survive a round trip through a number
+// cell. Codes with leading zeros stay text.
+var plainNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,14})(\.[0-9]{1,6})?$`)
+
+// WriteXLSX writes the rows to a single worksheet. The first row is taken as
+// the header and frozen.
+func WriteXLSX(w io.Writer, rows [][]string) error {
+	var sheet bytes.Buffer
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	sheet.WriteString(`<sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, v := range row {
+			ref := columnName(j) + strconv.Itoa(i+1)
+			if i > 0 && plainNumber.MatchString(v) {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, v)
+				continue
+			}
+			fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(&sheet, []byte(v))
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
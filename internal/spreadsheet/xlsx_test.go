@@ -0,0 +1,145 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// workbook builds a minimal xlsx file around the given worksheet XML and
+// optional shared strings, the way other spreadsheet programs write them.
+func workbook(t *testing.T, sheetData, sharedStrings string) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Тауарлар" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId7" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/data.xml"/>` +
+			`</Relationships>`,
+		"xl/worksheets/data.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			sheetData + `</sheetData></worksheet>`,
+	}
+	if sharedStrings != "" {
+		parts["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sharedStrings + `</sst>`
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := workbook(t,
+		`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>`+
+			`<row r="3"><c r="A3"><v>0.30000000000000004</v></c><c r="B3" t="b"><v>1</v></c>`+
+			`<c r="C3" t="str"><v>формула</v></c><c r="D3" t="inlineStr"><is><t>ішкі</t></is></c></row>`+
+			`<row><c><v>1E3</v></c><c><v>12500.5</v></c></row>`,
+		`<si><t>sku</t></si><si><r><t>ба</t></r><r><t>ға</t></r></si>`)
+
+	got, err := ReadXLSX(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"sku", "", "баға"},
+		nil,
+		{"0.3", "true", "формула", "ішкі"},
+		{"1000", "12500.5"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadXLSX = %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a zip", []byte("sku,price\n")},
+		{"shared string out of range", workbook(t, `<row r="1"><c r="A1" t="s"><v>5</v></c></row>`, `<si><t>sku</t></si>`)},
+		{"column too far", workbook(t, `<row r="1"><c r="ZZZ1"><v>1</v></c></row>`, "")},
+		{"row too far", workbook(t, `<row r="100001"><c r="A100001"><v>1</v></c></row>`, "")},
+		{"broken XML", workbook(t, `<row r="1"><c r="A1"><v>1</c></row>`, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadXLSX(tt.data); !errors.Is(err, ErrInvalidWorkbook) {
+				t.Errorf("ReadXLSX error = %v, want ErrInvalidWorkbook", err)
+			}
+		})
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	for col, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 255: "IV"} {
+		if got := columnName(col); got != name {
+			t.Errorf("columnName(%d) = %q, want %q", col, got, name)
+		}
+	}
+	for col := range maxColumns {
+		ref := columnName(col) + "12"
+		if got, err := columnIndex(ref); err != nil || got != col {
+			t.Errorf("columnIndex(%q) = %d, %v; want %d", ref, got, err, col)
+		}
+	}
+	if _, err := columnIndex("12"); !errors.Is(err, ErrInvalidWorkbook) {
+		t.Errorf("columnIndex without letters error = %v, want ErrInvalidWorkbook", err)
+	}
+}
+
+// Values that look like numbers but would lose their form in a number cell
+// are written as text.
+func TestWriteXLSXCellTypes(t *testing.T) {
+	var buf bytes.Buffer
+	rows := [][]string{{"123"}, {"123"}, {"007"}, {"1e3"}, {"12500.50"}, {"1234567890123456"}}
+	if err := WriteXLSX(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sheet = string(b)
+		}
+	}
+
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr">`, // the header is always text
+		`<c r="A2"><v>123</v></c>`,
+		`<c r="A3" t="inlineStr">`,
+		`<c r="A4" t="inlineStr">`,
+		`<c r="A5"><v>12500.50</v></c>`,
+		`<c r="A6" t="inlineStr">`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("worksheet does not contain %s", want)
+		}
+	}
+}